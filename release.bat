@@ -3,3 +3,4 @@ pushd src
 go build .
 popd
 xcopy src\Raku.exe release\windows\ /Y
+xcopy src\config.example.yaml release\windows\ /Y
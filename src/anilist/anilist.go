@@ -1,12 +1,16 @@
 package anilist
 
 import (
+	"Raku/metrics"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -21,6 +25,29 @@ const (
 	All    Season = 5
 )
 
+// MediaSort orders seasonal results.
+type MediaSort string
+
+const (
+	SortPopularity MediaSort = "POPULARITY_DESC"
+	SortScore      MediaSort = "SCORE_DESC"
+	SortTitle      MediaSort = "TITLE_ROMAJI"
+	SortStartDate  MediaSort = "START_DATE"
+)
+
+// MediaFormat filters seasonal results, FormatAll disables the filter.
+type MediaFormat string
+
+const (
+	FormatAll     MediaFormat = ""
+	FormatTV      MediaFormat = "TV"
+	FormatTVShort MediaFormat = "TV_SHORT"
+	FormatMovie   MediaFormat = "MOVIE"
+	FormatOVA     MediaFormat = "OVA"
+	FormatONA     MediaFormat = "ONA"
+	FormatSpecial MediaFormat = "SPECIAL"
+)
+
 var (
 	seasonNames = map[Season]string{
 		Winter: "WINTER",
@@ -33,6 +60,12 @@ var (
 	baseURL = "https://graphql.anilist.co/"
 )
 
+var (
+	requestsTotal      = metrics.NewCounterVec("raku_anilist_requests_total", "AniList queries by name and HTTP status, \"error\" when no response arrived.", "query", "status")
+	requestDuration    = metrics.NewHistogramVec("raku_anilist_request_duration_seconds", "Time taken by AniList queries.", metrics.DefaultBuckets, "query")
+	rateLimitRemaining = metrics.NewGaugeVec("raku_anilist_ratelimit_remaining", "Requests left in the AniList rate limit window as of the last response.")
+)
+
 type Title struct {
 	English string
 	Romaji  string
@@ -76,6 +109,7 @@ type PageInfo struct {
 	CurrentPage float64
 	PerPage     float64
 	HasNextPage bool
+	LastPage    float64
 }
 
 type Page struct {
@@ -87,6 +121,8 @@ type Page struct {
 type postParam struct {
 	Query     string                 `json:"query"`
 	Variables map[string]interface{} `json:"variables"`
+	// name identifies the query in logs.
+	name string
 }
 
 type errorLocation struct {
@@ -133,15 +169,54 @@ func buildError(errs []postError) error {
 	return errors.New(builder.String())
 }
 
-func executeQuery[T any](client *http.Client, param postParam) (*T, error) {
+// executeQuery logs the query with its duration and outcome, with the fields
+// of ctx such as the interaction it was made for.
+func executeQuery[T any](ctx context.Context, client *http.Client, param postParam) (*T, error) {
+	start := time.Now()
+	data, res, err := doQuery[T](ctx, client, param)
+	elapsed := time.Since(start)
+
+	attrs := []slog.Attr{
+		slog.String("query", param.name),
+		slog.Duration("duration", elapsed),
+	}
+
+	requestDuration.Observe(elapsed.Seconds(), param.name)
+	if res != nil {
+		attrs = append(attrs, slog.Int("status", res.StatusCode))
+		requestsTotal.Inc(param.name, strconv.Itoa(res.StatusCode))
+		if remaining, err := strconv.Atoi(res.Header.Get("X-RateLimit-Remaining")); err == nil {
+			rateLimitRemaining.Set(float64(remaining))
+		}
+	} else {
+		requestsTotal.Inc(param.name, "error")
+	}
+	if err != nil {
+		// AniList rejecting a query is a bug to report, unlike outages, rate
+		// limits and missing media.
+		level := slog.LevelWarn
+		if res != nil && res.StatusCode < 500 && res.StatusCode != http.StatusNotFound && res.StatusCode != http.StatusTooManyRequests {
+			level = slog.LevelError
+		}
+		attrs = append(attrs, slog.Any("err", err))
+		slog.LogAttrs(ctx, level, "anilist query failed", attrs...)
+	} else {
+		slog.LogAttrs(ctx, slog.LevelInfo, "anilist query", attrs...)
+	}
+
+	return data, err
+}
+
+// doQuery returns the response along with errors which came after it.
+func doQuery[T any](ctx context.Context, client *http.Client, param postParam) (*T, *http.Response, error) {
 	body, err := json.Marshal(param)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	req, err := http.NewRequest("POST", baseURL, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL, bytes.NewBuffer(body))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	req.Header.Add("Content-Type", "application/json")
@@ -150,7 +225,7 @@ func executeQuery[T any](client *http.Client, param postParam) (*T, error) {
 	res, err := client.Do(req)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer res.Body.Close()
@@ -159,14 +234,14 @@ func executeQuery[T any](client *http.Client, param postParam) (*T, error) {
 
 	err = json.NewDecoder(res.Body).Decode(&reply)
 	if err != nil {
-		return nil, err
+		return nil, res, err
 	}
 
 	if reply.Errors != nil {
-		return nil, buildError(reply.Errors)
+		return nil, res, buildError(reply.Errors)
 	}
 
-	return reply.Data, nil
+	return reply.Data, res, nil
 }
 
 func StringToSeason(str string) Season {
@@ -197,7 +272,7 @@ func MonthToSeason(month time.Month) Season {
 	return season
 }
 
-func FindMedia(client *http.Client, id int) (*Media, error) {
+func FindMedia(ctx context.Context, client *http.Client, id int) (*Media, error) {
 	q := `
 	query ($id: Int) {
 		Media (id: $id) {
@@ -238,9 +313,10 @@ func FindMedia(client *http.Client, id int) (*Media, error) {
 		Variables: map[string]interface{}{
 			"id": id,
 		},
+		name: "FindMedia",
 	}
 
-	res, err := executeQuery[postDataFindAnime](client, param)
+	res, err := executeQuery[postDataFindAnime](ctx, client, param)
 	if err != nil {
 		return nil, err
 	}
@@ -248,14 +324,15 @@ func FindMedia(client *http.Client, id int) (*Media, error) {
 	return &res.Media, nil
 }
 
-func SearchMedia(client *http.Client, media string, search string, limit int) (*Page, error) {
+func SearchMedia(ctx context.Context, client *http.Client, media string, search string, page int, limit int) (*Page, error) {
 	q := `
-	query ($type: MediaType, $tags: String, $limit: Int) {
-		Page (page: 1, perPage: $limit) {
+	query ($type: MediaType, $tags: String, $page: Int, $limit: Int) {
+		Page (page: $page, perPage: $limit) {
 			pageInfo {
 				currentPage,
 				perPage,
-				hasNextPage
+				hasNextPage,
+				lastPage
 			}
 		
 			media (search: $tags, type: $type) {
@@ -278,11 +355,13 @@ func SearchMedia(client *http.Client, media string, search string, limit int) (*
 		Variables: map[string]interface{}{
 			"type":  strings.ToUpper(media),
 			"tags":  search,
+			"page":  page,
 			"limit": limit,
 		},
+		name: "SearchMedia",
 	}
 
-	res, err := executeQuery[postDataFindSeasonal](client, param)
+	res, err := executeQuery[postDataFindSeasonal](ctx, client, param)
 	if err != nil {
 		return nil, err
 	}
@@ -292,17 +371,18 @@ func SearchMedia(client *http.Client, media string, search string, limit int) (*
 	return &res.Page, nil
 }
 
-func FindSeasonal(client *http.Client, page PageInfo, season Season, year int) (*Page, error) {
+func FindSeasonal(ctx context.Context, client *http.Client, page PageInfo, season Season, year int, sort MediaSort, format MediaFormat) (*Page, error) {
 	q := `
-	query ($page: Int, $perPage: Int, $season: MediaSeason, $year: Int) {
+	query ($page: Int, $perPage: Int, $season: MediaSeason, $year: Int, $sort: [MediaSort], $format: MediaFormat) {
 		Page (page: $page, perPage: $perPage) {
 			pageInfo {
 				currentPage,
 				perPage,
-				hasNextPage
+				hasNextPage,
+				lastPage
 			}
 		
-			media (season: $season, seasonYear: $year, type: ANIME, sort: POPULARITY_DESC) {
+			media (season: $season, seasonYear: $year, type: ANIME, sort: $sort, format: $format) {
 				id,
 				title {
 					romaji,
@@ -324,23 +404,33 @@ func FindSeasonal(client *http.Client, page PageInfo, season Season, year int) (
 			"page":    page.CurrentPage,
 			"perPage": page.PerPage,
 			"year":    year,
+			"sort":    []MediaSort{sort},
 		},
+		name: "FindSeasonal",
 	}
 
 	if season != All {
 		param.Variables["season"] = seasonNames[season]
 	}
+	if format != FormatAll {
+		param.Variables["format"] = format
+	}
 
-	res, err := executeQuery[postDataFindSeasonal](client, param)
+	res, err := executeQuery[postDataFindSeasonal](ctx, client, param)
 	if err != nil {
 		return nil, err
 	}
 
+	query := url.Values{}
+	query.Set("year", fmt.Sprint(year))
+	query.Set("sort", string(sort))
 	if season != All {
-		res.Page.URL = fmt.Sprintf("https://anilist.co/search/anime?year=%v&season=%v", year, seasonNames[season])
-	} else {
-		res.Page.URL = fmt.Sprintf("https://anilist.co/search/anime?year=%v", year)
+		query.Set("season", seasonNames[season])
+	}
+	if format != FormatAll {
+		query.Set("format", string(format))
 	}
+	res.Page.URL = "https://anilist.co/search/anime?" + query.Encode()
 
 	return &res.Page, nil
 }
@@ -3,8 +3,10 @@ package main
 import (
 	"Raku/anilist"
 	"Raku/botctx"
+	"Raku/config"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
@@ -12,191 +14,227 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
-var SearchAnimeCommand = botctx.CommandDesc{
-	Name:        "anime-search",
-	Description: "Search for anime",
-	Options: []*discordgo.ApplicationCommandOption{
-		{
-			Type:        discordgo.ApplicationCommandOptionString,
-			Name:        "search",
-			Description: "tags to search for anime",
-			Required:    true,
-		},
-	},
-	Func:        animeSearch,
-	Interaction: mediaSearchInteract,
+type animeSearchArgs struct {
+	Search string `option:"search" description:"tags to search for anime" required:"true" max:"100"`
 }
 
-var SearchMangaCommand = botctx.CommandDesc{
-	Name:        "manga-search",
-	Description: "Search for manga",
-	Options: []*discordgo.ApplicationCommandOption{
-		{
-			Type:        discordgo.ApplicationCommandOptionString,
-			Name:        "search",
-			Description: "tags to search for manga",
-			Required:    true,
-		},
-	},
-	Func:        mangaSearch,
-	Interaction: mediaSearchInteract,
+type mangaSearchArgs struct {
+	Search string `option:"search" description:"tags to search for manga" required:"true" max:"100"`
+}
+
+type animeSeasonalArgs struct {
+	Year   int    `option:"year" description:"Released year" min:"1940" max:"5000" default:"current year"`
+	Season string `option:"season" description:"Season" choices:"all=ALL,winter=WINTER,spring=SPRING,summer=SUMMER,fall=FALL" default:"current season"`
+}
+
+// mediaSearchQuery is the state of a search result paginator.
+type mediaSearchQuery struct {
+	MediaType string
+	Search    string
+}
+
+// animeSeasonalFilter is the state of the seasonal browser.
+type animeSeasonalFilter struct {
+	Year   int
+	Season anilist.Season
+	Sort   anilist.MediaSort
+	Format anilist.MediaFormat
 }
 
-var SeasonalCommand = botctx.CommandDesc{
-	Name:        "anime-seasonal",
-	Description: "List of seasonal anime",
-	Options: []*discordgo.ApplicationCommandOption{
+// The labels of the filters are translated from "anime.seasonal.sorts.<key>"
+// and "anime.seasonal.formats.<key>".
+var seasonalSorts = []struct {
+	Key  string
+	Sort anilist.MediaSort
+}{
+	{"popularity", anilist.SortPopularity},
+	{"score", anilist.SortScore},
+	{"title", anilist.SortTitle},
+	{"start_date", anilist.SortStartDate},
+}
+
+var seasonalFormats = []struct {
+	Key    string
+	Format anilist.MediaFormat
+}{
+	{"all", anilist.FormatAll},
+	{"tv", anilist.FormatTV},
+	{"tv_short", anilist.FormatTVShort},
+	{"movie", anilist.FormatMovie},
+	{"ova", anilist.FormatOVA},
+	{"ona", anilist.FormatONA},
+	{"special", anilist.FormatSpecial},
+}
+
+var (
+	animeSearchPaginator = newMediaSearchPaginator("anime")
+	mangaSearchPaginator = newMediaSearchPaginator("manga")
+
+	animeSeasonalPaginator = botctx.NewPaginator(botctx.PaginatorDesc[animeSeasonalFilter, *anilist.Page]{
+		Route:     botctx.ComponentID("anime", "seasonal"),
+		Fetch:     fetchSeasonalAnime,
+		Options:   seasonalAnimeOptions,
+		Render:    renderSeasonalAnime,
+		Action:    seasonalAnimeAction,
+		OwnerOnly: true,
+	})
+)
+
+var AnimeCommand = botctx.CommandDesc{
+	Name:        "anime",
+	Description: "Search and browse anime",
+	Subcommands: []botctx.CommandDesc{
 		{
-			Type:        discordgo.ApplicationCommandOptionInteger,
-			Name:        "year",
-			Description: "Released year",
-			Required:    false,
-			MaxValue:    5000,
+			Name:        "search",
+			Description: "Search for anime",
+			Options:     botctx.OptionsOf[animeSearchArgs](),
+			Func:        botctx.Bind(animeSearch),
+			Interaction: animeSearchPaginator.Interaction(),
+			Autocomplete: map[string]botctx.AutocompleteFunc{
+				"search": animeSearchAutocomplete,
+			},
+			Examples: []string{"/anime search search:frieren"},
 		},
 		{
-			Type:        discordgo.ApplicationCommandOptionString,
-			Name:        "season",
-			Description: "Season",
-			Required:    false,
-			Choices: []*discordgo.ApplicationCommandOptionChoice{
-				{
-					Name:  "all",
-					Value: "ALL",
-				},
-				{
-					Name:  "winter",
-					Value: "WINTER",
-				},
-				{
-					Name:  "spring",
-					Value: "SPRING",
-				},
-				{
-					Name:  "summer",
-					Value: "SUMMER",
-				},
-				{
-					Name:  "fall",
-					Value: "FALL",
-				},
+			Name:        "seasonal",
+			Description: "List of seasonal anime",
+			Options:     botctx.OptionsOf[animeSeasonalArgs](),
+			Func:        botctx.Bind(animeSeasonal),
+			Interaction: animeSeasonalPaginator.Interaction(),
+			Examples:    []string{"/anime seasonal", "/anime seasonal year:2023 season:fall"},
+			Cooldown: config.Cooldown{
+				Duration: 5 * time.Second,
+				Scope:    config.ScopeUser,
 			},
 		},
 	},
-	Func:        animeSeasonal,
-	Interaction: animeSeasonalInteract,
 }
 
-func animeSearch(session *discordgo.Session, i *discordgo.InteractionCreate) {
-	doMediaSearch(session, i, "anime")
+var MangaCommand = botctx.CommandDesc{
+	Name:        "manga",
+	Description: "Search manga",
+	Subcommands: []botctx.CommandDesc{
+		{
+			Name:        "search",
+			Description: "Search for manga",
+			Options:     botctx.OptionsOf[mangaSearchArgs](),
+			Func:        botctx.Bind(mangaSearch),
+			Interaction: mangaSearchPaginator.Interaction(),
+			Autocomplete: map[string]botctx.AutocompleteFunc{
+				"search": mangaSearchAutocomplete,
+			},
+			Examples: []string{"/manga search search:berserk"},
+		},
+	},
 }
 
-func mangaSearch(session *discordgo.Session, i *discordgo.InteractionCreate) {
-	doMediaSearch(session, i, "manga")
+// AnimeLookupCommand searches anime by the text of a message, such as a
+// title someone mentioned in chat.
+var AnimeLookupCommand = botctx.CommandDesc{
+	Type: discordgo.MessageApplicationCommand,
+	Name: "Look up on AniList",
+	Func: animeLookup,
+	Cooldown: config.Cooldown{
+		Duration: 5 * time.Second,
+		Scope:    config.ScopeUser,
+	},
 }
 
-func mediaSearchInteract(session *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
-	if len(args) != 1 {
-		return
-	}
-
-	id, _ := strconv.ParseInt(args[0], 10, 32)
-
-	embed := &discordgo.MessageEmbed{
-		Title:       "N/A",
-		Description: "N/A",
-		Type:        discordgo.EmbedTypeRich,
-		Color:       0xdd1111,
+func animeLookup(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate) {
+	search := ""
+	if msg := botctx.TargetMessage(i); msg != nil {
+		search = strings.TrimSpace(msg.Content)
 	}
-	media, err := anilist.FindMedia(session.Client, int(id))
 
-	if err == nil {
-		embed = createMediaEmbed(media)
-	}
-
-	body := &discordgo.InteractionResponseData{
-		Embeds: []*discordgo.MessageEmbed{embed},
+	if search == "" {
+		data := mediaErrorData(ctx, botctx.Tr(ctx, "anime.no_text"))
+		data.Flags = discordgo.MessageFlagsEphemeral
+		botctx.ResponderFrom(ctx).Respond(&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: data,
+		})
+		return
 	}
 
-	res := &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseUpdateMessage,
-		Data: body,
+	// AniList matches titles, long messages would only find nothing.
+	if runes := []rune(search); len(runes) > 100 {
+		search = string(runes[:100])
 	}
 
-	err = session.InteractionRespond(i.Interaction, res)
-	if err != nil {
-		log.Printf("error: mediaSearchInteract: %+v\n", err)
-	}
+	doMediaSearch(ctx, session, i, "anime", search)
 }
 
-func animeSeasonal(session *discordgo.Session, i *discordgo.InteractionCreate) {
-	now := time.Now()
-	page := 1
-	year := now.Year()
-	season := anilist.MonthToSeason(now.Month())
-
-	data := i.ApplicationCommandData()
-	for _, option := range data.Options {
-		if option.Name == "year" {
-			year = int(option.IntValue())
-		} else if option.Name == "season" {
-			season = anilist.StringToSeason(option.StringValue())
-		}
-	}
-
-	body := doSeasonalAnime(session, page, 0, year, season)
+func animeSearch(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, args *animeSearchArgs) {
+	doMediaSearch(ctx, session, i, "anime", args.Search)
+}
 
-	res := &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: body,
-	}
+func mangaSearch(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, args *mangaSearchArgs) {
+	doMediaSearch(ctx, session, i, "manga", args.Search)
+}
 
-	err := session.InteractionRespond(i.Interaction, res)
-	if err != nil {
-		log.Printf("error: animeSeasonal: %+v\n", err)
-	}
+func animeSearchAutocomplete(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, option *discordgo.ApplicationCommandInteractionDataOption) []*discordgo.ApplicationCommandOptionChoice {
+	return doMediaAutocomplete(ctx, session, option.StringValue(), "anime")
 }
 
-func animeSeasonalInteract(session *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
-	data := i.MessageComponentData()
+func mangaSearchAutocomplete(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, option *discordgo.ApplicationCommandInteractionDataOption) []*discordgo.ApplicationCommandOptionChoice {
+	return doMediaAutocomplete(ctx, session, option.StringValue(), "manga")
+}
 
-	if len(args) != 3 {
-		return
+func animeSeasonal(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, args *animeSeasonalArgs) {
+	now := time.Now()
+	filter := animeSeasonalFilter{
+		Year:   now.Year(),
+		Season: anilist.MonthToSeason(now.Month()),
+		Sort:   anilist.SortPopularity,
+		Format: anilist.FormatAll,
 	}
 
-	page, _ := strconv.ParseInt(args[0], 10, 32)
-	year, _ := strconv.ParseInt(args[1], 10, 32)
-	season, _ := strconv.ParseInt(args[2], 10, 32)
-	var index int64
-
-	if data.Values != nil {
-		index, _ = strconv.ParseInt(data.Values[0], 10, 32)
+	if args.Year != 0 {
+		filter.Year = args.Year
+	}
+	if args.Season != "" {
+		filter.Season = anilist.StringToSeason(args.Season)
 	}
 
-	body := doSeasonalAnime(session, int(page), int(index), int(year), anilist.Season(season))
+	animeSeasonalPaginator.Start(ctx, session, i, filter)
+}
 
-	res := &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseUpdateMessage,
-		Data: body,
+func seasonalAnimeAction(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, filter *animeSeasonalFilter, name string, values []string) bool {
+	if len(values) == 0 {
+		return false
 	}
 
-	err := session.InteractionRespond(i.Interaction, res)
-	if err != nil {
-		log.Printf("error: animeSeasonalInteract: %+v\n", err)
+	switch name {
+	case "sort":
+		for _, sort := range seasonalSorts {
+			if string(sort.Sort) == values[0] {
+				filter.Sort = sort.Sort
+				return true
+			}
+		}
+	case "format":
+		for _, format := range seasonalFormats {
+			if seasonalFormatValue(format.Format) == values[0] {
+				filter.Format = format.Format
+				return true
+			}
+		}
 	}
+	return false
 }
 
 //
 //
 //
 
-func createMediaEmbed(media *anilist.Media) *discordgo.MessageEmbed {
+func createMediaEmbed(ctx context.Context, media *anilist.Media) *discordgo.MessageEmbed {
 	var color int64
 	if len(media.CoverImage.Color) > 1 {
 		hex := media.CoverImage.Color[1 : len(media.CoverImage.Color)-1]
 		color, _ = strconv.ParseInt(hex, 16, 32)
 	}
 
-	description := "N/A"
+	description := botctx.Tr(ctx, "anime.na")
 
 	if len(media.Description) > 1 {
 		description = media.Description
@@ -221,7 +259,7 @@ func createMediaEmbed(media *anilist.Media) *discordgo.MessageEmbed {
 		}
 	}
 
-	alt := "N/A"
+	alt := botctx.Tr(ctx, "anime.na")
 
 	if len(media.Title.English) > 1 {
 		alt = media.Title.English
@@ -230,13 +268,13 @@ func createMediaEmbed(media *anilist.Media) *discordgo.MessageEmbed {
 	start := media.StartDate
 	end := media.EndDate
 
-	date := "N/A"
+	date := botctx.Tr(ctx, "anime.na")
 
 	if start.Year != 0 {
 		date = fmt.Sprintf("%02d/%02d/%04d", start.Day, start.Month, start.Year)
 
 		if end.Year != 0 {
-			date += fmt.Sprintf(" to %02d/%02d/%04d", end.Day, end.Month, end.Year)
+			date = botctx.Tr(ctx, "anime.date_range", date, fmt.Sprintf("%02d/%02d/%04d", end.Day, end.Month, end.Year))
 		}
 	}
 
@@ -244,21 +282,21 @@ func createMediaEmbed(media *anilist.Media) *discordgo.MessageEmbed {
 	var releaseCount = 0
 
 	if media.Type == "ANIME" {
-		releaseName = "Episodes"
+		releaseName = botctx.Tr(ctx, "anime.fields.episodes")
 		releaseCount = media.Episodes
 	} else {
-		releaseName = "Volumes"
+		releaseName = botctx.Tr(ctx, "anime.fields.volumes")
 		releaseCount = media.Volumes
 	}
 
 	fields := []*discordgo.MessageEmbedField{
 		{
-			Name:   "Alt",
+			Name:   botctx.Tr(ctx, "anime.fields.alt"),
 			Value:  alt,
 			Inline: true,
 		},
 		{
-			Name:   "Aired",
+			Name:   botctx.Tr(ctx, "anime.fields.aired"),
 			Value:  date,
 			Inline: true,
 		},
@@ -268,13 +306,13 @@ func createMediaEmbed(media *anilist.Media) *discordgo.MessageEmbed {
 			Inline: true,
 		},
 		{
-			Name:   "Score",
+			Name:   botctx.Tr(ctx, "anime.fields.score"),
 			Value:  fmt.Sprint(media.MeanScore),
 			Inline: true,
 		},
 		{
-			Name:   "Status",
-			Value:  media.Status,
+			Name:   botctx.Tr(ctx, "anime.fields.status"),
+			Value:  mediaStatus(ctx, media.Status),
 			Inline: true,
 		},
 		{
@@ -283,7 +321,7 @@ func createMediaEmbed(media *anilist.Media) *discordgo.MessageEmbed {
 			Inline: true,
 		},
 		{
-			Name:  "Genres",
+			Name:  botctx.Tr(ctx, "anime.fields.genres"),
 			Value: builder.String(),
 		},
 	}
@@ -300,185 +338,323 @@ func createMediaEmbed(media *anilist.Media) *discordgo.MessageEmbed {
 	return &embed
 }
 
-func encodeSeasonalAnimeId(page float64, year int, season anilist.Season) string {
-	return fmt.Sprintf("anime-seasonal;%v;%v;%v", int(page), year, int(season))
+// mediaStatus translates a status like "RELEASING", AniList may add ones
+// without a translation which are shown as they are.
+func mediaStatus(ctx context.Context, status string) string {
+	key := "anime.status." + status
+	if str := botctx.Tr(ctx, key); str != key {
+		return str
+	}
+	return status
 }
 
-func doMediaSearch(session *discordgo.Session, i *discordgo.InteractionCreate, mediaType string) {
-	search := "example"
+// Autocomplete suggestions carry the media id instead of the title so that
+// picking one can skip the search and show the media directly.
+func encodeMediaValue(id int) string {
+	return fmt.Sprintf("id:%v", id)
+}
 
-	data := i.ApplicationCommandData()
-	for _, option := range data.Options {
-		if option.Name == "search" {
-			search = option.StringValue()
+func decodeMediaValue(value string) (int, bool) {
+	str, ok := strings.CutPrefix(value, "id:")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(str, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int(id), true
+}
+
+func doMediaAutocomplete(ctx context.Context, session botctx.Session, search string, mediaType string) []*discordgo.ApplicationCommandOptionChoice {
+	search = strings.TrimSpace(search)
+	if len(search) == 0 {
+		return nil
+	}
+
+	page, err := anilist.SearchMedia(ctx, session.HTTPClient(), mediaType, search, 1, cfg.Anime.AutocompleteLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "doMediaAutocomplete", "err", err)
+		return nil
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(page.Media))
+	for _, media := range page.Media {
+		name := media.Title.Romaji
+		if media.StartDate.Year != 0 {
+			name = fmt.Sprintf("%v (%v)", botctx.Truncate(name, 93), media.StartDate.Year)
 		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  botctx.Truncate(name, 100),
+			Value: encodeMediaValue(media.Id),
+		})
+	}
+	return choices
+}
+
+// seasonalFormatValue is the select menu value of format, which cannot be
+// empty like FormatAll.
+func seasonalFormatValue(format anilist.MediaFormat) string {
+	return "format:" + string(format)
+}
+
+func mediaErrorData(ctx context.Context, desc string) *discordgo.InteractionResponseData {
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title:       botctx.Tr(ctx, "anime.error"),
+				Description: desc,
+				Color:       cfg.Colors.Error,
+			},
+		},
+	}
+}
+
+func doMediaSearch(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, mediaType string, search string) {
+	if id, ok := decodeMediaValue(search); ok {
+		doMediaFind(ctx, session, i, id)
+		return
 	}
 
-	page, err := anilist.SearchMedia(session.Client, mediaType, search, 3)
+	query := mediaSearchQuery{
+		MediaType: mediaType,
+		Search:    search,
+	}
+
+	if mediaType == "manga" {
+		mangaSearchPaginator.Start(ctx, session, i, query)
+	} else {
+		animeSearchPaginator.Start(ctx, session, i, query)
+	}
+}
+
+func newMediaSearchPaginator(mediaType string) *botctx.Paginator[mediaSearchQuery, *anilist.Page] {
+	return botctx.NewPaginator(botctx.PaginatorDesc[mediaSearchQuery, *anilist.Page]{
+		Route:     botctx.ComponentID(mediaType, "search"),
+		Fetch:     fetchMediaSearch,
+		Options:   mediaSearchOptions,
+		Render:    renderMediaSearch,
+		OwnerOnly: true,
+	})
+}
+
+func fetchMediaSearch(ctx context.Context, session botctx.Session, query *mediaSearchQuery, number int) (*anilist.Page, botctx.PageInfo, error) {
+	page, err := anilist.SearchMedia(ctx, session.HTTPClient(), query.MediaType, query.Search, number, cfg.Anime.SearchLimit)
+	if err != nil {
+		return nil, botctx.PageInfo{}, err
+	}
+	return page, botctx.PageInfo{HasNext: page.PageInfo.HasNextPage, Last: int(page.PageInfo.LastPage)}, nil
+}
+
+func mediaSearchOptions(view *botctx.PageView[mediaSearchQuery, *anilist.Page]) []discordgo.SelectMenuOption {
+	options := make([]discordgo.SelectMenuOption, 0, len(view.Items.Media))
+	for idx, media := range view.Items.Media {
+		options = append(options, discordgo.SelectMenuOption{
+			Label: botctx.Truncate(fmt.Sprintf("%v. %v", idx+1, media.Title.Romaji), 100),
+			Value: fmt.Sprint(media.Id),
+		})
+	}
+	return options
+}
+
+// renderMediaSearch lists the results of a page, or shows the one picked
+// from the select menu.
+func renderMediaSearch(ctx context.Context, session botctx.Session, view *botctx.PageView[mediaSearchQuery, *anilist.Page]) *discordgo.InteractionResponseData {
+	if view.Err != nil {
+		return nil
+	}
+	page := view.Items
 
 	embed := &discordgo.MessageEmbed{
-		Title:       "N/A",
-		Description: "N/A",
+		Title:       botctx.Tr(ctx, "anime.na"),
+		Description: botctx.Tr(ctx, "anime.na"),
 		Type:        discordgo.EmbedTypeRich,
-		Color:       0xdd1111,
+		Color:       cfg.Colors.Error,
 	}
 
-	buttons := make([]discordgo.MessageComponent, 0, 3)
-
-	if err == nil {
+	if id, err := strconv.Atoi(view.Selected); err == nil {
+		media, err := anilist.FindMedia(ctx, session.HTTPClient(), id)
+		if err == nil {
+			embed = createMediaEmbed(ctx, media)
+		} else {
+			botctx.ResponderFrom(ctx).Fail()
+		}
+	} else if len(page.Media) > 0 {
 		fields := make([]*discordgo.MessageEmbedField, len(page.Media))
-
 		for idx, media := range page.Media {
-			buttons = append(buttons, discordgo.Button{
-				Label:    fmt.Sprint(idx + 1),
-				Style:    discordgo.PrimaryButton,
-				CustomID: fmt.Sprintf("%v-search;%v", mediaType, media.Id),
-			})
 			fields[idx] = &discordgo.MessageEmbedField{
 				Name:  fmt.Sprintf("%v. %v", idx+1, media.Title.Romaji),
 				Value: media.Title.English,
 			}
 		}
-		buttons = append(buttons, discordgo.Button{
-			Label: "Open",
-			Style: discordgo.LinkButton,
-			URL:   page.URL,
-		})
 
 		embed = &discordgo.MessageEmbed{
-			Title:  "Results for " + search,
+			Title:  botctx.Tr(ctx, "anime.results_for", view.State.Search),
 			Type:   discordgo.EmbedTypeRich,
-			Color:  0x11dddd,
+			Color:  cfg.Colors.Info,
 			Fields: fields,
 		}
 	}
 
-	body := &discordgo.InteractionResponseData{
+	return &discordgo.InteractionResponseData{
 		Embeds: []*discordgo.MessageEmbed{embed},
-	}
-
-	if len(buttons) > 0 {
-		body.Components = []discordgo.MessageComponent{
+		Components: []discordgo.MessageComponent{
 			discordgo.ActionsRow{
-				Components: buttons,
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label: botctx.Tr(ctx, "anime.open"),
+						Style: discordgo.LinkButton,
+						URL:   page.URL,
+					},
+				},
 			},
-		}
+		},
+	}
+}
+
+// doMediaFind shows the media picked from the autocomplete suggestions, or
+// N/A when AniList does not have it.
+func doMediaFind(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, id int) {
+	embed := &discordgo.MessageEmbed{
+		Title:       botctx.Tr(ctx, "anime.na"),
+		Description: botctx.Tr(ctx, "anime.na"),
+		Type:        discordgo.EmbedTypeRich,
+		Color:       cfg.Colors.Error,
+	}
+	media, err := anilist.FindMedia(ctx, session.HTTPClient(), id)
+
+	if err == nil {
+		embed = createMediaEmbed(ctx, media)
+	} else {
+		botctx.ResponderFrom(ctx).Fail()
 	}
 
 	res := &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: body,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
 	}
 
-	err = session.InteractionRespond(i.Interaction, res)
+	err = botctx.ResponderFrom(ctx).Respond(res)
 	if err != nil {
-		log.Printf("error: doMediaSearch: %+v\n", err)
+		slog.ErrorContext(ctx, "doMediaFind", "err", err)
 	}
 }
 
-func doSeasonalAnime(session *discordgo.Session, currentPage int, index int, year int, season anilist.Season) *discordgo.InteractionResponseData {
+func fetchSeasonalAnime(ctx context.Context, session botctx.Session, filter *animeSeasonalFilter, number int) (*anilist.Page, botctx.PageInfo, error) {
 	info := anilist.PageInfo{
-		CurrentPage: float64(currentPage),
-		PerPage:     16,
+		CurrentPage: float64(number),
+		PerPage:     float64(cfg.Anime.SeasonalPageSize),
 	}
 
-	page, err := anilist.FindSeasonal(session.Client, info, season, year)
-
+	page, err := anilist.FindSeasonal(ctx, session.HTTPClient(), info, filter.Season, filter.Year, filter.Sort, filter.Format)
 	if err != nil {
-		log.Printf("error: doSeasonalAnime: %+v\n", err)
-		return nil
+		return nil, botctx.PageInfo{}, err
 	}
+	return page, botctx.PageInfo{HasNext: page.PageInfo.HasNextPage, Last: int(page.PageInfo.LastPage)}, nil
+}
 
-	if len(page.Media) == 0 {
-		data := &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{
-				{
-					Title:       "Error",
-					Description: "No seasonal anime found",
-					Color:       0xdd1111,
-				},
-			},
-		}
-		return data
+func seasonalAnimeOptions(view *botctx.PageView[animeSeasonalFilter, *anilist.Page]) []discordgo.SelectMenuOption {
+	options := make([]discordgo.SelectMenuOption, 0, len(view.Items.Media))
+	for _, media := range view.Items.Media {
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       botctx.Truncate(media.Title.Romaji, 50),
+			Value:       fmt.Sprint(media.Id),
+			Description: fmt.Sprintf("%02d/%02d", media.StartDate.Month, media.StartDate.Year),
+		})
 	}
+	return options
+}
 
-	if index >= len(page.Media) {
-		index = 0
+// renderSeasonalAnime shows the picked anime, or the first one of the page,
+// above the sort and format filters.
+func renderSeasonalAnime(ctx context.Context, session botctx.Session, view *botctx.PageView[animeSeasonalFilter, *anilist.Page]) *discordgo.InteractionResponseData {
+	page := view.Items
+	filter := view.State
+
+	sorts := make([]discordgo.SelectMenuOption, 0, len(seasonalSorts))
+	for _, sort := range seasonalSorts {
+		sorts = append(sorts, discordgo.SelectMenuOption{
+			Label:   botctx.Tr(ctx, "anime.seasonal.sorts."+sort.Key),
+			Value:   string(sort.Sort),
+			Default: sort.Sort == filter.Sort,
+		})
 	}
 
-	var options []discordgo.SelectMenuOption
-
-	for idx, media := range page.Media {
-		label := media.Title.Romaji
-
-		if len(label) > 50 {
-			label = label[0:50]
-		}
-
-		option := discordgo.SelectMenuOption{
-			Label:       label,
-			Value:       fmt.Sprint(idx),
-			Description: fmt.Sprintf("%02d/%02d", media.StartDate.Month, media.StartDate.Year),
-		}
-		options = append(options, option)
+	formats := make([]discordgo.SelectMenuOption, 0, len(seasonalFormats))
+	for _, format := range seasonalFormats {
+		formats = append(formats, discordgo.SelectMenuOption{
+			Label:   botctx.Tr(ctx, "anime.seasonal.formats."+format.Key),
+			Value:   seasonalFormatValue(format.Format),
+			Default: format.Format == filter.Format,
+		})
 	}
 
-	buttons := []discordgo.MessageComponent{
+	components := []discordgo.MessageComponent{
 		discordgo.ActionsRow{
 			Components: []discordgo.MessageComponent{
 				discordgo.SelectMenu{
-					MenuType:    discordgo.StringSelectMenu,
-					CustomID:    encodeSeasonalAnimeId(page.PageInfo.CurrentPage, year, season),
-					Placeholder: page.Media[index].Title.Romaji,
-					Options:     options,
+					MenuType: discordgo.StringSelectMenu,
+					CustomID: view.ActionID("sort"),
+					Options:  sorts,
 				},
 			},
 		},
 		discordgo.ActionsRow{
 			Components: []discordgo.MessageComponent{
-				discordgo.Button{
-					Label:    fmt.Sprintf("Page %v", page.PageInfo.CurrentPage),
-					Style:    discordgo.SecondaryButton,
-					CustomID: fmt.Sprintf("anime-seasonal-page-%v", page.PageInfo.CurrentPage),
-					Disabled: true,
-				},
-				discordgo.Button{
-					Label:    "Prev",
-					Style:    discordgo.PrimaryButton,
-					CustomID: encodeSeasonalAnimeId(page.PageInfo.CurrentPage-1, year, season),
-					Disabled: info.CurrentPage == 1,
-				},
-				discordgo.Button{
-					Label:    "Next",
-					Style:    discordgo.PrimaryButton,
-					CustomID: encodeSeasonalAnimeId(info.CurrentPage+1, year, season),
-					Disabled: !page.PageInfo.HasNextPage,
-				},
-				discordgo.Button{
-					Label: "Open",
-					Style: discordgo.LinkButton,
-					URL:   page.URL,
+				discordgo.SelectMenu{
+					MenuType: discordgo.StringSelectMenu,
+					CustomID: view.ActionID("format"),
+					Options:  formats,
 				},
 			},
 		},
 	}
 
+	// The filters stay when the page failed to load, so another one can
+	// be picked.
+	if view.Err != nil {
+		return &discordgo.InteractionResponseData{Components: components}
+	}
+
+	components = append(components, discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label: botctx.Tr(ctx, "anime.open"),
+				Style: discordgo.LinkButton,
+				URL:   page.URL,
+			},
+		},
+	})
+
+	if len(page.Media) == 0 {
+		data := mediaErrorData(ctx, botctx.Tr(ctx, "anime.seasonal.empty"))
+		data.Components = components
+		return data
+	}
+
+	id := page.Media[0].Id
+	if selected, err := strconv.Atoi(view.Selected); err == nil {
+		id = selected
+	}
+
 	embed := &discordgo.MessageEmbed{
-		Title:       "N/A",
-		Description: "N/A",
+		Title:       botctx.Tr(ctx, "anime.na"),
+		Description: botctx.Tr(ctx, "anime.na"),
 		Type:        discordgo.EmbedTypeRich,
-		Color:       0xdd1111,
+		Color:       cfg.Colors.Error,
 	}
-	media, err := anilist.FindMedia(session.Client, page.Media[index].Id)
+	media, err := anilist.FindMedia(ctx, session.HTTPClient(), id)
 
 	if err == nil {
-		embed = createMediaEmbed(media)
+		embed = createMediaEmbed(ctx, media)
+	} else {
+		botctx.ResponderFrom(ctx).Fail()
 	}
 
-	data := &discordgo.InteractionResponseData{
+	return &discordgo.InteractionResponseData{
 		Embeds:     []*discordgo.MessageEmbed{embed},
-		Components: buttons,
+		Components: components,
 	}
-
-	return data
 }
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"Raku/botctx"
+	"Raku/botctx/fake"
+	"Raku/discordtest"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// anilistClient answers AniList queries with the status and body reply
+// returns for their variables.
+func anilistClient(reply func(vars map[string]any) (int, string)) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body struct {
+			Variables map[string]any
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+
+		status, data := reply(body.Variables)
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Type", "application/json")
+		rec.WriteHeader(status)
+		rec.WriteString(data)
+		return rec.Result(), nil
+	})}
+}
+
+const (
+	anilistPage     = `{"data":{"Page":{"pageInfo":{"currentPage":1,"hasNextPage":false,"lastPage":1},"media":[{"id":42,"title":{"romaji":"Sousou no Frieren","english":"Frieren"}},{"id":43,"title":{"romaji":"Frieren 2","english":""}}]}}}`
+	anilistEmpty    = `{"data":{"Page":{"pageInfo":{"currentPage":1,"hasNextPage":false,"lastPage":1},"media":[]}}}`
+	anilistMedia    = `{"data":{"Media":{"id":42,"type":"ANIME","title":{"romaji":"Sousou no Frieren","english":"Frieren"},"siteUrl":"https://anilist.co/anime/42","status":"FINISHED"}}}`
+	anilistNotFound = `{"errors":[{"message":"Not Found.","status":404}],"data":{"Media":null}}`
+	anilistDown     = `{"errors":[{"message":"Internal Server Error","status":500}],"data":null}`
+)
+
+func commandInteraction() *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			ID:        discordtest.Snowflake(time.Now(), 0),
+			Type:      discordgo.InteractionApplicationCommand,
+			ChannelID: testSourceID,
+			GuildID:   testGuildID,
+			Member:    &discordgo.Member{User: testUser, Permissions: discordgo.PermissionAll},
+			Token:     "token",
+		},
+	}
+}
+
+func responseEmbed(t *testing.T, session *fake.Session) *discordgo.MessageEmbed {
+	t.Helper()
+	if len(session.Responses) != 1 || session.Responses[0].Data == nil || len(session.Responses[0].Data.Embeds) != 1 {
+		t.Fatalf("unexpected responses %+v", session.Responses)
+	}
+	return session.Responses[0].Data.Embeds[0]
+}
+
+func TestDoMediaSearch(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+		search    string
+		status    int
+		body      string
+		vars      map[string]any
+		title     string
+		fields    int
+		failed    bool
+	}{
+		{
+			name:      "results",
+			mediaType: "anime",
+			search:    "frieren",
+			status:    http.StatusOK,
+			body:      anilistPage,
+			vars:      map[string]any{"type": "ANIME", "tags": "frieren"},
+			title:     "Results for frieren",
+			fields:    2,
+		},
+		{
+			name:      "manga",
+			mediaType: "manga",
+			search:    "frieren",
+			status:    http.StatusOK,
+			body:      anilistPage,
+			vars:      map[string]any{"type": "MANGA"},
+			title:     "Results for frieren",
+			fields:    2,
+		},
+		{
+			name:      "no results",
+			mediaType: "anime",
+			search:    "nothing",
+			status:    http.StatusOK,
+			body:      anilistEmpty,
+			title:     "N/A",
+		},
+		{
+			name:      "autocompleted id",
+			mediaType: "anime",
+			search:    encodeMediaValue(42),
+			status:    http.StatusOK,
+			body:      anilistMedia,
+			vars:      map[string]any{"id": float64(42)},
+			title:     "Sousou no Frieren",
+			fields:    7,
+		},
+		{
+			name:      "autocompleted id not found",
+			mediaType: "anime",
+			search:    encodeMediaValue(41),
+			status:    http.StatusNotFound,
+			body:      anilistNotFound,
+			title:     "N/A",
+			failed:    true,
+		},
+		{
+			name:      "anilist down",
+			mediaType: "anime",
+			search:    "frieren",
+			status:    http.StatusInternalServerError,
+			body:      anilistDown,
+			title:     botctx.Translate(discordgo.EnglishUS, "botctx.paginator.load_failed_title"),
+			failed:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := fake.New()
+			session.Client = anilistClient(func(vars map[string]any) (int, string) {
+				for key, want := range test.vars {
+					if vars[key] != want {
+						t.Errorf("variable %v is %v, want %v", key, vars[key], want)
+					}
+				}
+				return test.status, test.body
+			})
+
+			i := commandInteraction()
+			ctx := botctx.WithInteraction(context.Background(), session, i)
+			doMediaSearch(ctx, session, i, test.mediaType, test.search)
+
+			embed := responseEmbed(t, session)
+			if embed.Title != test.title {
+				t.Errorf("title is %q, want %q", embed.Title, test.title)
+			}
+			if len(embed.Fields) != test.fields {
+				t.Errorf("%v fields, want %v", len(embed.Fields), test.fields)
+			}
+			if failed := botctx.ResponderFrom(ctx).Failed(); failed != test.failed {
+				t.Errorf("failed is %v, want %v", failed, test.failed)
+			}
+		})
+	}
+}
+
+func TestAnimeSeasonal(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        animeSeasonalArgs
+		page        string
+		status      int
+		vars        map[string]any
+		title       string
+		description string
+		// rows are the action rows of the message, filters included.
+		rows int
+	}{
+		{
+			name:   "season",
+			args:   animeSeasonalArgs{Year: 2023, Season: "FALL"},
+			page:   anilistPage,
+			status: http.StatusOK,
+			vars:   map[string]any{"year": float64(2023), "season": "FALL", "page": float64(1)},
+			title:  "Sousou no Frieren",
+			rows:   5,
+		},
+		{
+			name:   "all seasons",
+			args:   animeSeasonalArgs{Year: 2023, Season: "ALL"},
+			page:   anilistPage,
+			status: http.StatusOK,
+			vars:   map[string]any{"season": nil},
+			title:  "Sousou no Frieren",
+			rows:   5,
+		},
+		{
+			name:        "empty",
+			args:        animeSeasonalArgs{Year: 1900, Season: "WINTER"},
+			page:        anilistEmpty,
+			status:      http.StatusOK,
+			title:       "Error",
+			description: "No seasonal anime found",
+			rows:        4,
+		},
+		{
+			name:   "anilist down",
+			args:   animeSeasonalArgs{Year: 2023, Season: "FALL"},
+			page:   anilistDown,
+			status: http.StatusInternalServerError,
+			title:  botctx.Translate(discordgo.EnglishUS, "botctx.paginator.load_failed_title"),
+			rows:   3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := fake.New()
+			session.Client = anilistClient(func(vars map[string]any) (int, string) {
+				if _, ok := vars["id"]; ok {
+					return http.StatusOK, anilistMedia
+				}
+				for key, want := range test.vars {
+					if vars[key] != want {
+						t.Errorf("variable %v is %v, want %v", key, vars[key], want)
+					}
+				}
+				return test.status, test.page
+			})
+
+			i := commandInteraction()
+			ctx := botctx.WithInteraction(context.Background(), session, i)
+			animeSeasonal(ctx, session, i, &test.args)
+
+			embed := responseEmbed(t, session)
+			if embed.Title != test.title {
+				t.Errorf("title is %q, want %q", embed.Title, test.title)
+			}
+			if test.description != "" && embed.Description != test.description {
+				t.Errorf("description is %q, want %q", embed.Description, test.description)
+			}
+			if rows := len(session.Responses[0].Data.Components); rows != test.rows {
+				t.Errorf("%v rows, want %v", rows, test.rows)
+			}
+		})
+	}
+}
+
+func TestAnimeSeasonalOwnerOnly(t *testing.T) {
+	session := fake.New()
+	session.Client = anilistClient(func(vars map[string]any) (int, string) {
+		if _, ok := vars["id"]; ok {
+			return http.StatusOK, anilistMedia
+		}
+		return http.StatusOK, anilistPage
+	})
+
+	i := commandInteraction()
+	ctx := botctx.WithInteraction(context.Background(), session, i)
+	animeSeasonal(ctx, session, i, &animeSeasonalArgs{Year: 2023, Season: "FALL"})
+
+	rows := session.Responses[0].Data.Components
+	next := rows[len(rows)-1].(discordgo.ActionsRow).Components[3].(discordgo.Button)
+	_, args, err := botctx.DecodeCustomID(next.CustomID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		user  *discordgo.User
+		title string
+	}{
+		{"owner", testUser, "Sousou no Frieren"},
+		{"other", testOther, botctx.Translate(discordgo.EnglishUS, "botctx.paginator.not_yours_title")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session.Responses = nil
+			click := &discordgo.InteractionCreate{
+				Interaction: &discordgo.Interaction{
+					ID:        discordtest.Snowflake(time.Now(), 1),
+					Type:      discordgo.InteractionMessageComponent,
+					ChannelID: testSourceID,
+					GuildID:   testGuildID,
+					Member:    &discordgo.Member{User: test.user},
+					Data:      discordgo.MessageComponentInteractionData{CustomID: next.CustomID, ComponentType: discordgo.ButtonComponent},
+					Token:     "token",
+				},
+			}
+			ctx := botctx.WithInteraction(context.Background(), session, click)
+			animeSeasonalPaginator.Interaction()(ctx, session, click, args)
+
+			if embed := responseEmbed(t, session); embed.Title != test.title {
+				t.Errorf("title is %q, want %q", embed.Title, test.title)
+			}
+		})
+	}
+}
@@ -1,39 +1,100 @@
 package botctx
 
 import (
+	"Raku/config"
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-type CommandFunc func(session *discordgo.Session, i *discordgo.InteractionCreate)
-type InteractionFunc func(session *discordgo.Session, i *discordgo.InteractionCreate, args []string)
+type CommandFunc func(ctx context.Context, session Session, i *discordgo.InteractionCreate)
+type InteractionFunc func(ctx context.Context, session Session, i *discordgo.InteractionCreate, args []string)
+type AutocompleteFunc func(ctx context.Context, session Session, i *discordgo.InteractionCreate, option *discordgo.ApplicationCommandInteractionDataOption) []*discordgo.ApplicationCommandOptionChoice
 
 type Command struct {
-	Command     *discordgo.ApplicationCommand
-	Func        CommandFunc
-	Interaction InteractionFunc
+	Path         string
+	Command      *discordgo.ApplicationCommand
+	Func         CommandFunc
+	Interaction  InteractionFunc
+	Autocomplete map[string]AutocompleteFunc
+	Subcommands  map[string]Command
+	Cooldown     config.Cooldown
+	Examples     []string
+
+	MemberPermissions int64
+	BotPermissions    int64
+	Checks            []CheckFunc
 }
 
+// A CommandDesc with Subcommands is only a container: its own Func and
+// Options are not used. Nesting a CommandDesc with Subcommands inside
+// another one declares a subcommand group.
 type CommandDesc struct {
+	// Type is a chat input command by default. User and message commands
+	// appear in the context menu of users and messages, they have no
+	// description, options or subcommands and their Func reads the target
+	// with TargetUser or TargetMessage.
+	Type        discordgo.ApplicationCommandType
 	Name        string
 	Description string
 	Func        CommandFunc
 	Interaction InteractionFunc
 	Options     []*discordgo.ApplicationCommandOption
+	Subcommands []CommandDesc
+	// Autocomplete maps option names to the handler suggesting their values.
+	Autocomplete map[string]AutocompleteFunc
+	// Cooldown applies to the command and, unless they declare their own,
+	// to its subcommands. It can be overridden in the config file.
+	Cooldown config.Cooldown
+	// Examples are shown by /help as typed, e.g. "/anime search search:frieren".
+	Examples []string
+
+	// DefaultMemberPermissions and DMPermission are only used on top level
+	// commands, where discord enforces them before the command is shown.
+	DefaultMemberPermissions *int64
+	DMPermission             *bool
+
+	// MemberPermissions and BotPermissions are verified in the invoking
+	// channel before Func runs, followed by Check. Subcommands add to the
+	// requirements of the command containing them.
+	MemberPermissions int64
+	BotPermissions    int64
+	Check             CheckFunc
 }
 
 var (
 	commandLUT = make(map[string]Command)
-	commands   = make([]*discordgo.ApplicationCommand, 0, 16)
+	// menuLUT holds the context menu commands by menuKey, their names may
+	// contain spaces and clash with chat input commands.
+	menuLUT      = make(map[string]Command)
+	componentLUT = make(map[string]InteractionFunc)
+	commands     = make([]*discordgo.ApplicationCommand, 0, 16)
+	cfg          = config.Default()
+
+	// rootCtx is the parent of every handler context and is cancelled when
+	// the bot shuts down.
+	rootCtx, cancelRoot = context.WithCancel(context.Background())
 )
 
-func Login(token string) {
-	bot, err := discordgo.New("Bot " + token)
+const (
+	AckTimeout   = 3 * time.Second
+	TokenTimeout = 15 * time.Minute
+)
+
+func Login(conf *config.Config) {
+	cfg = conf
+
+	bot, err := discordgo.New("Bot " + cfg.Token)
 	if err != nil {
 		log.Fatalf("error: %+v", err)
 	}
@@ -41,20 +102,55 @@ func Login(token string) {
 	for _, v := range commandLUT {
 		commands = append(commands, v.Command)
 	}
+	for _, v := range menuLUT {
+		commands = append(commands, v.Command)
+	}
+	for _, cmd := range commands {
+		localizeCommand(cmd)
+	}
+
+	applyCooldowns(cfg.Cooldowns)
+
+	stateStore, err = openStateStore(cfg.State.Dir)
+	if err != nil {
+		log.Fatalf("error: state store: %+v", err)
+	}
 
 	usd := discordgo.UpdateStatusData{}
 
 	bot.UpdateStatusComplex(usd)
 
 	bot.AddHandler(ready)
+	bot.AddHandler(guildCreate)
+	bot.AddHandler(guildDelete)
 	bot.AddHandler(interactionCreate)
+	bot.AddHandler(gatewayConnect)
+	bot.AddHandler(gatewayDisconnect)
+	bot.AddHandler(gatewayResumed)
+	gateway.Store(bot)
 
-	bot.Identify.Intents = discordgo.IntentGuildMessages | discordgo.IntentGuildIntegrations
+	bot.Identify.Intents = discordgo.IntentGuilds | discordgo.IntentGuildMessages | discordgo.IntentGuildIntegrations
+
+	if cfg.TextCommands.Prefix != "" {
+		buildTextCommands()
+		bot.AddHandler(messageCreate)
+		bot.Identify.Intents |= discordgo.IntentMessageContent
+	}
 
 	if err != nil {
 		log.Fatalln("Failed to create bot", err)
 	}
 
+	startReporting(bot)
+
+	var server *http.Server
+	if cfg.Metrics.Address != "" {
+		server, err = serveMetrics(cfg.Metrics.Address)
+		if err != nil {
+			log.Fatalf("error: metrics server: %+v", err)
+		}
+	}
+
 	err = bot.Open()
 	if err != nil {
 		log.Fatalln("Failed to open bot", err)
@@ -64,19 +160,303 @@ func Login(token string) {
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	<-sc
 
+	slog.Info("shutting down, waiting for running interactions", "timeout", cfg.Shutdown.Timeout)
+	connected.Store(false)
+	shutdown(cfg.Shutdown.Timeout)
 	bot.Close()
+	if server != nil {
+		server.Close()
+	}
+}
+
+func InteractionTime(i *discordgo.InteractionCreate) time.Time {
+	created, err := discordgo.SnowflakeTimestamp(i.ID)
+	if err != nil {
+		return time.Now()
+	}
+	return created
+}
+
+// AckDeadline is the time by which discord expects the first response to
+// the interaction.
+func AckDeadline(i *discordgo.InteractionCreate) time.Time {
+	return InteractionTime(i).Add(AckTimeout)
 }
 
 func RegisterApplicationCommand(desc CommandDesc) {
-	commandLUT[desc.Name] = Command{
-		Command: &discordgo.ApplicationCommand{
-			Name:        desc.Name,
-			Description: desc.Description,
-			Options:     desc.Options,
-		},
-		Func:        desc.Func,
-		Interaction: desc.Interaction,
+	if desc.Type == discordgo.UserApplicationCommand || desc.Type == discordgo.MessageApplicationCommand {
+		registerMenuCommand(desc)
+		return
+	}
+
+	cmd := buildCommand(desc, []string{desc.Name}, Command{})
+	cmd.Command = &discordgo.ApplicationCommand{
+		Name:                     desc.Name,
+		Description:              desc.Description,
+		Options:                  buildOptions(desc, []string{desc.Name}),
+		DefaultMemberPermissions: desc.DefaultMemberPermissions,
+		DMPermission:             desc.DMPermission,
+	}
+	commandLUT[desc.Name] = cmd
+}
+
+// ComponentID is the route of components and modals belonging to the
+// command at path, see EncodeCustomID.
+func ComponentID(path ...string) string {
+	return strings.Join(path, " ")
+}
+
+func CommandOptions(data discordgo.ApplicationCommandInteractionData) []*discordgo.ApplicationCommandInteractionDataOption {
+	options := data.Options
+	for len(options) > 0 && isSubcommand(options[0].Type) {
+		options = options[0].Options
+	}
+	return options
+}
+
+// Truncate shortens str to at most length runes, ending it with an ellipsis
+// when it was cut, to fit the length limits of names, labels and embeds.
+func Truncate(str string, length int) string {
+	runes := []rune(str)
+	if len(runes) <= length {
+		return str
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+func isSubcommand(kind discordgo.ApplicationCommandOptionType) bool {
+	return kind == discordgo.ApplicationCommandOptionSubCommand || kind == discordgo.ApplicationCommandOptionSubCommandGroup
+}
+
+func buildCommand(desc CommandDesc, path []string, parent Command) Command {
+	cmd := Command{
+		Path:              ComponentID(path...),
+		Func:              desc.Func,
+		Interaction:       desc.Interaction,
+		Autocomplete:      desc.Autocomplete,
+		Cooldown:          parent.Cooldown,
+		Examples:          desc.Examples,
+		MemberPermissions: parent.MemberPermissions | desc.MemberPermissions,
+		BotPermissions:    parent.BotPermissions | desc.BotPermissions,
+		Checks:            parent.Checks,
+	}
+
+	if desc.Cooldown.Scope != "" {
+		cmd.Cooldown = desc.Cooldown
+	}
+	if desc.Check != nil {
+		cmd.Checks = append(cmd.Checks[:len(cmd.Checks):len(cmd.Checks)], desc.Check)
+	}
+
+	if desc.Interaction != nil {
+		componentLUT[ComponentID(path...)] = desc.Interaction
+	}
+
+	if len(desc.Subcommands) > 0 {
+		cmd.Subcommands = make(map[string]Command, len(desc.Subcommands))
+		for _, sub := range desc.Subcommands {
+			cmd.Subcommands[sub.Name] = buildCommand(sub, append(path[:len(path):len(path)], sub.Name), cmd)
+		}
+	}
+
+	return cmd
+}
+
+func buildOptions(desc CommandDesc, path []string) []*discordgo.ApplicationCommandOption {
+	if len(desc.Subcommands) == 0 {
+		for _, option := range desc.Options {
+			_, option.Autocomplete = desc.Autocomplete[option.Name]
+		}
+		return desc.Options
+	}
+
+	if desc.Func != nil || len(desc.Options) > 0 {
+		log.Fatalf("error: command %q has subcommands and cannot have its own handler or options", ComponentID(path...))
+	}
+
+	options := make([]*discordgo.ApplicationCommandOption, 0, len(desc.Subcommands))
+	for _, sub := range desc.Subcommands {
+		subPath := append(path[:len(path):len(path)], sub.Name)
+
+		option := &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        sub.Name,
+			Description: sub.Description,
+			Options:     buildOptions(sub, subPath),
+		}
+
+		if len(sub.Subcommands) > 0 {
+			if len(path) > 1 {
+				log.Fatalf("error: command %q nests subcommand groups deeper than discord allows", ComponentID(subPath...))
+			}
+			option.Type = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+
+		options = append(options, option)
+	}
+	return options
+}
+
+// applyCooldowns overrides the cooldowns of the commands at the paths of
+// cooldowns. Parents go first, so that an override for a subcommand wins over
+// the one for the command containing it.
+func applyCooldowns(cooldowns map[string]config.Cooldown) {
+	paths := make([]string, 0, len(cooldowns))
+	for path := range cooldowns {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(a, b int) bool {
+		return len(strings.Fields(paths[a])) < len(strings.Fields(paths[b]))
+	})
+	for _, path := range paths {
+		if !overrideCooldown(path, cooldowns[path]) {
+			slog.Warn("cooldown configured for unknown command", "command", path)
+		}
+	}
+}
+
+func overrideCooldown(path string, cooldown config.Cooldown) bool {
+	found := false
+	for key, cmd := range menuLUT {
+		if cmd.Command.Name == path {
+			cmd.Cooldown = cooldown
+			menuLUT[key] = cmd
+			found = true
+		}
+	}
+	if found {
+		return true
+	}
+
+	names := strings.Fields(path)
+	if len(names) == 0 {
+		return false
+	}
+
+	var update func(cmd Command, depth int) (Command, bool)
+	update = func(cmd Command, depth int) (Command, bool) {
+		if depth == len(names) {
+			cmd.Cooldown = cooldown
+			for name, sub := range cmd.Subcommands {
+				cmd.Subcommands[name], _ = update(sub, depth)
+			}
+			return cmd, true
+		}
+		sub, ok := cmd.Subcommands[names[depth]]
+		if !ok {
+			return cmd, false
+		}
+		cmd.Subcommands[names[depth]], ok = update(sub, depth+1)
+		return cmd, ok
+	}
+
+	cmd, ok := commandLUT[names[0]]
+	if !ok {
+		return false
+	}
+	commandLUT[names[0]], ok = update(cmd, 1)
+	return ok
+}
+
+func menuKey(kind discordgo.ApplicationCommandType, name string) string {
+	return fmt.Sprintf("%v:%v", kind, name)
+}
+
+func registerMenuCommand(desc CommandDesc) {
+	if desc.Description != "" || len(desc.Options) > 0 || len(desc.Subcommands) > 0 || len(desc.Autocomplete) > 0 {
+		log.Fatalf("error: context menu command %q cannot have a description, options or subcommands", desc.Name)
+	}
+
+	cmd := buildCommand(desc, []string{desc.Name}, Command{})
+	cmd.Command = &discordgo.ApplicationCommand{
+		Type:                     desc.Type,
+		Name:                     desc.Name,
+		DefaultMemberPermissions: desc.DefaultMemberPermissions,
+		DMPermission:             desc.DMPermission,
+	}
+	menuLUT[menuKey(desc.Type, desc.Name)] = cmd
+}
+
+// menuByName finds a context menu command by its name, which is also its
+// path.
+func menuByName(name string) (*Command, bool) {
+	for _, kind := range []discordgo.ApplicationCommandType{discordgo.MessageApplicationCommand, discordgo.UserApplicationCommand} {
+		if _, ok := menuLUT[menuKey(kind, name)]; ok {
+			cmd := menuLUT[menuKey(kind, name)]
+			return &cmd, true
+		}
+	}
+	return nil, false
+}
+
+// commandType tells the kind of command invoked, discordgo does not decode
+// it so it is taken from the resolved target.
+func commandType(data discordgo.ApplicationCommandInteractionData) discordgo.ApplicationCommandType {
+	if data.TargetID == "" {
+		return discordgo.ChatApplicationCommand
+	}
+	if data.Resolved != nil && data.Resolved.Messages[data.TargetID] != nil {
+		return discordgo.MessageApplicationCommand
+	}
+	return discordgo.UserApplicationCommand
+}
+
+// TargetMessage is the message a message command was used on.
+func TargetMessage(i *discordgo.InteractionCreate) *discordgo.Message {
+	data := i.ApplicationCommandData()
+	if data.Resolved == nil {
+		return nil
+	}
+	return data.Resolved.Messages[data.TargetID]
+}
+
+// TargetUser is the user a user command was used on, with Member set when
+// it was used in a guild.
+func TargetUser(i *discordgo.InteractionCreate) (*discordgo.User, *discordgo.Member) {
+	data := i.ApplicationCommandData()
+	if data.Resolved == nil {
+		return nil, nil
+	}
+	user := data.Resolved.Users[data.TargetID]
+	member := data.Resolved.Members[data.TargetID]
+	if member != nil {
+		member.User = user
 	}
+	return user, member
+}
+
+func commandByPath(path string) (Command, bool) {
+	if cmd, ok := menuByName(path); ok {
+		return *cmd, true
+	}
+
+	names := strings.Fields(path)
+	if len(names) == 0 {
+		return Command{}, false
+	}
+	cmd, ok := commandLUT[names[0]]
+	for _, name := range names[1:] {
+		if !ok {
+			break
+		}
+		cmd, ok = cmd.Subcommands[name]
+	}
+	return cmd, ok
+}
+
+func findCommand(data discordgo.ApplicationCommandInteractionData) (Command, bool) {
+	if kind := commandType(data); kind != discordgo.ChatApplicationCommand {
+		cmd, ok := menuLUT[menuKey(kind, data.Name)]
+		return cmd, ok && cmd.Func != nil
+	}
+
+	cmd, ok := commandLUT[data.Name]
+	options := data.Options
+	for ok && len(cmd.Subcommands) > 0 && len(options) > 0 && isSubcommand(options[0].Type) {
+		cmd, ok = cmd.Subcommands[options[0].Name]
+		options = options[0].Options
+	}
+	return cmd, ok && cmd.Func != nil
 }
 
 //
@@ -84,29 +464,190 @@ func RegisterApplicationCommand(desc CommandDesc) {
 //
 
 func ready(session *discordgo.Session, r *discordgo.Ready) {
-	log.Printf("Logged in as %v#%v", r.User.Username, r.User.Discriminator)
-	for _, guild := range r.Guilds {
-		session.ApplicationCommandBulkOverwrite(session.State.User.ID, guild.ID, commands)
+	connected.Store(true)
+	slog.Info("logged in", "user", r.User.Username+"#"+r.User.Discriminator, "user_id", r.User.ID, "guilds", len(r.Guilds))
+
+	// Every guild, including the ones listed here, is followed by a
+	// GuildCreate event, so guild commands are left to guildCreate.
+	switch cfg.Commands.Registration {
+	case config.RegisterGlobal:
+		syncCommands(session, "")
+	case config.RegisterDev:
+		syncCommands(session, cfg.Commands.DevGuild)
+	}
+	if cfg.Commands.Registration != config.RegisterGlobal {
+		clearCommands(session, "")
 	}
 }
 
 func guildCreate(session *discordgo.Session, e *discordgo.GuildCreate) {
-	session.ApplicationCommandBulkOverwrite(session.State.User.ID, e.Guild.ID, commands)
+	switch {
+	case cfg.Commands.Registration == config.RegisterGuild:
+		syncCommands(session, e.Guild.ID)
+	case cfg.Commands.Registration == config.RegisterGlobal || e.Guild.ID != cfg.Commands.DevGuild:
+		clearCommands(session, e.Guild.ID)
+	}
+}
+
+// guildDelete removes the commands of a guild the bot left. Outages are
+// reported as GuildDelete too, with Unavailable set, and keep them.
+func guildDelete(session *discordgo.Session, e *discordgo.GuildDelete) {
+	if cfg.Commands.Registration != config.RegisterGuild || e.Unavailable {
+		return
+	}
+	_, err := session.ApplicationCommandBulkOverwrite(session.State.User.ID, e.Guild.ID, []*discordgo.ApplicationCommand{})
+	if err != nil {
+		slog.Error("guildDelete", "guild_id", e.Guild.ID, "err", err)
+	}
+}
+
+func errorResponse(title string, desc string) *discordgo.InteractionResponse {
+	return &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       title,
+					Description: desc,
+					Type:        discordgo.EmbedTypeRich,
+					Color:       cfg.Colors.Error,
+				},
+			},
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	}
+}
+
+func respondError(ctx context.Context, title string, desc string) {
+	r := ResponderFrom(ctx)
+	r.Fail()
+	err := r.Respond(errorResponse(title, desc))
+	if err != nil {
+		slog.ErrorContext(ctx, "respondError", "err", err)
+	}
+}
+
+func autocomplete(ctx context.Context, session Session, i *discordgo.InteractionCreate, cmd Command) {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+
+	for _, option := range CommandOptions(i.ApplicationCommandData()) {
+		if !option.Focused {
+			continue
+		}
+		fn, ok := cmd.Autocomplete[option.Name]
+		if ok {
+			choices = fn(ctx, session, i, option)
+		}
+		break
+	}
+
+	if len(choices) > 25 {
+		choices = choices[:25]
+	}
+
+	res := &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	}
+
+	err := ResponderFrom(ctx).Respond(res)
+	if err != nil {
+		slog.ErrorContext(ctx, "autocomplete", "err", err)
+	}
 }
 
 func interactionCreate(session *discordgo.Session, i *discordgo.InteractionCreate) {
+	HandleInteraction(Wrap(session), i)
+}
+
+// HandleInteraction routes an interaction to the handler registered for it.
+// The gateway calls it for every interaction, it is exported so that
+// handlers can be driven by a fake Session.
+func HandleInteraction(session Session, i *discordgo.InteractionCreate) {
+	var handler HandlerFunc
+	// name labels the metrics of the interaction, custom IDs are too many
+	// to be used as is.
+	name := "unknown"
+
 	if i.Type == discordgo.InteractionApplicationCommand {
-		data := i.ApplicationCommandData()
-		cmd, ok := commandLUT[data.Name]
+		cmd, ok := findCommand(i.ApplicationCommandData())
 		if ok {
-			cmd.Func(session, i)
+			name = cmd.Path
+			handler = withPermissions(cmd, withCooldown(cmd, HandlerFunc(cmd.Func)))
 		}
-	} else if i.Type == discordgo.InteractionMessageComponent {
-		data := i.MessageComponentData()
-		args := strings.Split(data.CustomID, ";")
-		cmd, ok := commandLUT[args[0]]
+	} else if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		cmd, ok := findCommand(i.ApplicationCommandData())
 		if ok {
-			cmd.Interaction(session, i, args[1:])
+			name = cmd.Path
+			handler = func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+				autocomplete(ctx, session, i, cmd)
+			}
+		}
+	} else if i.Type == discordgo.InteractionMessageComponent {
+		route, args, err := DecodeCustomID(i.MessageComponentData().CustomID)
+		fn, ok := componentLUT[route]
+		if err != nil || !ok {
+			handler = func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+				respondCustomIDError(ctx, customIDError(err))
+			}
+		} else {
+			name = route
+			handler = func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+				fn(ctx, session, i, args)
+			}
 		}
+	} else if i.Type == discordgo.InteractionModalSubmit {
+		route, args, err := DecodeCustomID(i.ModalSubmitData().CustomID)
+		fn, ok := modalLUT[route]
+		if err != nil || !ok {
+			handler = func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+				respondCustomIDError(ctx, customIDError(err))
+			}
+		} else {
+			name = route
+			handler = func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+				fn(ctx, session, i, args)
+			}
+			// A modal registered under a command's path finishes that
+			// command, so it shares the command's concurrency limit. The
+			// cooldown was already charged when the command opened it.
+			if cmd, ok := commandByPath(route); ok {
+				cmd.Cooldown.Duration = 0
+				handler = withCooldown(cmd, handler)
+			}
+		}
+	}
+
+	if handler == nil {
+		return
+	}
+
+	// Handlers may keep working on an interaction for as long as its token
+	// is valid, except for autocomplete which only has one response.
+	deadline := InteractionTime(i).Add(TokenTimeout)
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		deadline = AckDeadline(i)
+	}
+
+	ctx, cancel := context.WithDeadline(rootCtx, deadline)
+	defer cancel()
+
+	responder := newResponder(session, i.Interaction, cfg.Interactions.DeferAfter)
+	defer responder.stop()
+
+	if !inflight.track(responder) {
+		notifyRestart(responder)
+		return
+	}
+	defer inflight.untrack(responder)
+
+	start := time.Now()
+	chain(handler)(withResponder(WithLogFields(ctx, interactionLogFields(i)...), responder), session, i)
+	observeInteraction(name, i, responder, time.Since(start))
+
+	if Stopping(ctx) {
+		notifyRestart(responder)
 	}
 }
@@ -0,0 +1,108 @@
+package botctx
+
+import (
+	"Raku/config"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type limiter struct {
+	mutex   sync.Mutex
+	until   map[string]time.Time
+	running map[string]int
+}
+
+var limits = limiter{
+	until:   make(map[string]time.Time),
+	running: make(map[string]int),
+}
+
+func cooldownKey(path string, cooldown config.Cooldown, i *discordgo.InteractionCreate) string {
+	id := ""
+	switch cooldown.Scope {
+	case config.ScopeUser:
+		if user := InteractionUser(i); user != nil {
+			id = user.ID
+		}
+	case config.ScopeChannel:
+		id = i.ChannelID
+	case config.ScopeGuild:
+		id = i.GuildID
+		if id == "" {
+			id = i.ChannelID
+		}
+	}
+	return fmt.Sprintf("%v|%v:%v", path, cooldown.Scope, id)
+}
+
+// acquire reports how long the caller has to wait before running again, or
+// false when too many invocations are already running.
+func (l *limiter) acquire(key string, cooldown config.Cooldown) (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+
+	if until, ok := l.until[key]; ok && now.Before(until) {
+		return until.Sub(now), true
+	}
+
+	if cooldown.Concurrency > 0 && l.running[key] >= cooldown.Concurrency {
+		return 0, false
+	}
+
+	if len(l.until) > 1024 {
+		for k, until := range l.until {
+			if now.After(until) {
+				delete(l.until, k)
+			}
+		}
+	}
+
+	if cooldown.Duration > 0 {
+		l.until[key] = now.Add(cooldown.Duration)
+	}
+	l.running[key]++
+
+	return 0, true
+}
+
+func (l *limiter) release(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.running[key]--
+	if l.running[key] <= 0 {
+		delete(l.running, key)
+	}
+}
+
+func withCooldown(cmd Command, next HandlerFunc) HandlerFunc {
+	cooldown := cmd.Cooldown
+	if cooldown.Scope == "" {
+		return next
+	}
+
+	return func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+		key := cooldownKey(cmd.Path, cooldown, i)
+
+		wait, ok := limits.acquire(key, cooldown)
+		if !ok {
+			respondError(ctx, Tr(ctx, "botctx.cooldown.title"), Tr(ctx, "botctx.cooldown.running"))
+			return
+		}
+		if wait > 0 {
+			seconds := int(math.Ceil(wait.Seconds()))
+			respondError(ctx, Tr(ctx, "botctx.cooldown.title"), Tr(ctx, "botctx.cooldown.wait", seconds))
+			return
+		}
+
+		defer limits.release(key)
+		next(ctx, session, i)
+	}
+}
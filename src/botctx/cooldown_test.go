@@ -0,0 +1,202 @@
+package botctx
+
+import (
+	"Raku/config"
+	"Raku/discordtest"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func cooldownInteraction(guildID string, channelID string, userID string) *discordgo.InteractionCreate {
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        discordtest.Snowflake(time.Now(), 0),
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   guildID,
+		ChannelID: channelID,
+		Token:     "token",
+	}}
+	if guildID == "" {
+		i.User = &discordgo.User{ID: userID}
+	} else {
+		i.Member = &discordgo.Member{User: &discordgo.User{ID: userID}}
+	}
+	return i
+}
+
+func TestCooldownKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope string
+		a, b  *discordgo.InteractionCreate
+		same  bool
+	}{
+		{"user across channels", config.ScopeUser, cooldownInteraction(testGuildID, "1", "10"), cooldownInteraction(testGuildID, "2", "10"), true},
+		{"user apart", config.ScopeUser, cooldownInteraction(testGuildID, "1", "10"), cooldownInteraction(testGuildID, "1", "11"), false},
+		{"channel across users", config.ScopeChannel, cooldownInteraction(testGuildID, "1", "10"), cooldownInteraction(testGuildID, "1", "11"), true},
+		{"guild across users", config.ScopeGuild, cooldownInteraction(testGuildID, "1", "10"), cooldownInteraction(testGuildID, "2", "11"), true},
+		{"guild apart", config.ScopeGuild, cooldownInteraction(testGuildID, "1", "10"), cooldownInteraction("200000000000000002", "2", "10"), false},
+		{"guild in direct messages", config.ScopeGuild, cooldownInteraction("", "1", "10"), cooldownInteraction("", "2", "10"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cooldown := config.Cooldown{Duration: time.Minute, Scope: test.scope}
+			a, b := cooldownKey("anime", cooldown, test.a), cooldownKey("anime", cooldown, test.b)
+			if (a == b) != test.same {
+				t.Errorf("keys %q and %q, want same %v", a, b, test.same)
+			}
+		})
+	}
+}
+
+func newLimiter() *limiter {
+	return &limiter{until: make(map[string]time.Time), running: make(map[string]int)}
+}
+
+func TestLimiterDuration(t *testing.T) {
+	l := newLimiter()
+	cooldown := config.Cooldown{Duration: time.Minute, Scope: config.ScopeUser}
+
+	if wait, ok := l.acquire("a", cooldown); wait != 0 || !ok {
+		t.Fatalf("first acquire waits %v, %v", wait, ok)
+	}
+	l.release("a")
+
+	if wait, ok := l.acquire("a", cooldown); wait <= 0 || wait > time.Minute || !ok {
+		t.Errorf("second acquire waits %v, %v", wait, ok)
+	}
+	if wait, ok := l.acquire("b", cooldown); wait != 0 || !ok {
+		t.Errorf("other key waits %v, %v", wait, ok)
+	}
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	l := newLimiter()
+	cooldown := config.Cooldown{Scope: config.ScopeGuild, Concurrency: 2}
+
+	for n := 0; n < 2; n++ {
+		if _, ok := l.acquire("a", cooldown); !ok {
+			t.Fatalf("acquire %v refused", n)
+		}
+	}
+	if _, ok := l.acquire("a", cooldown); ok {
+		t.Error("acquired past the concurrency cap")
+	}
+
+	l.release("a")
+	if _, ok := l.acquire("a", cooldown); !ok {
+		t.Error("acquire refused after a release")
+	}
+
+	l.release("a")
+	l.release("a")
+	if n := len(l.running); n != 0 {
+		t.Errorf("%v keys left running", n)
+	}
+}
+
+func TestWithCooldown(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope string
+		// second is who runs the command after user 10 did.
+		second *discordgo.InteractionCreate
+		ran    int
+	}{
+		{"user again", config.ScopeUser, cooldownInteraction(testGuildID, "1", "10"), 1},
+		{"other user", config.ScopeUser, cooldownInteraction(testGuildID, "1", "11"), 2},
+		{"guild other user", config.ScopeGuild, cooldownInteraction(testGuildID, "2", "11"), 1},
+		{"other guild", config.ScopeGuild, cooldownInteraction("200000000000000002", "2", "10"), 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := discordtest.NewServer()
+			defer srv.Close()
+			session := Wrap(srv.Session())
+
+			ran := 0
+			cmd := Command{Path: "cooldown " + test.name, Cooldown: config.Cooldown{Duration: time.Minute, Scope: test.scope}}
+			handler := withCooldown(cmd, func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+				ran++
+			})
+
+			for _, i := range []*discordgo.InteractionCreate{cooldownInteraction(testGuildID, "1", "10"), test.second} {
+				handler(WithInteraction(context.Background(), session, i), session, i)
+			}
+
+			if ran != test.ran {
+				t.Errorf("ran %v times, want %v", ran, test.ran)
+			}
+			if callbacks := srv.Callbacks(); len(callbacks) != 2-test.ran {
+				t.Errorf("%v cooldown errors, want %v", len(callbacks), 2-test.ran)
+			}
+		})
+	}
+}
+
+func TestWithCooldownPanic(t *testing.T) {
+	srv := discordtest.NewServer()
+	defer srv.Close()
+	session := Wrap(srv.Session())
+
+	cmd := Command{Path: "cooldown panic", Cooldown: config.Cooldown{Scope: config.ScopeUser, Concurrency: 1}}
+	handler := Recover()(withCooldown(cmd, func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+		panic("handler failed")
+	}))
+
+	for n := 0; n < 2; n++ {
+		i := cooldownInteraction(testGuildID, "1", "10")
+		handler(WithInteraction(context.Background(), session, i), session, i)
+	}
+
+	key := cooldownKey(cmd.Path, cmd.Cooldown, cooldownInteraction(testGuildID, "1", "10"))
+	limits.mutex.Lock()
+	running := limits.running[key]
+	limits.mutex.Unlock()
+	if running != 0 {
+		t.Errorf("%v invocations still counted as running", running)
+	}
+
+	// Both panicked rather than the second hitting the concurrency cap.
+	for _, callback := range srv.Callbacks() {
+		if callback.Data.Embeds[0].Description != Translate(DefaultLocale, "botctx.error.unexpected") {
+			t.Errorf("answered %q", callback.Data.Embeds[0].Description)
+		}
+	}
+}
+
+func TestApplyCooldowns(t *testing.T) {
+	t.Cleanup(func() { delete(commandLUT, "cooltest") })
+
+	noop := func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {}
+	parent := config.Cooldown{Duration: time.Minute, Scope: config.ScopeUser}
+	child := config.Cooldown{Duration: 5 * time.Second, Scope: config.ScopeGuild}
+
+	// Map order is random, so the overrides are applied a few times.
+	for n := 0; n < 20; n++ {
+		RegisterApplicationCommand(CommandDesc{
+			Name:        "cooltest",
+			Description: "cooltest",
+			Subcommands: []CommandDesc{
+				{Name: "a", Description: "a", Func: noop},
+				{Name: "b", Description: "b", Func: noop},
+			},
+		})
+
+		applyCooldowns(map[string]config.Cooldown{
+			"cooltest a": child,
+			"cooltest":   parent,
+			"missing":    child,
+		})
+
+		a, _ := commandByPath("cooltest a")
+		b, _ := commandByPath("cooltest b")
+		if a.Cooldown != child || b.Cooldown != parent {
+			t.Fatalf("cooldowns %+v and %+v, want %+v and %+v", a.Cooldown, b.Cooldown, child, parent)
+		}
+	}
+}
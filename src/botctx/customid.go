@@ -0,0 +1,240 @@
+package botctx
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom IDs look like "v1;anime seasonal;2;2024;4" followed by ";" and a
+// signature when a secret is configured. The route is the command path the
+// component belongs to and the rest are the fields of its payload in
+// declaration order.
+const (
+	CustomIDVersion   = "v1"
+	CustomIDMaxLength = 100
+
+	customIDSignatureLength = 9
+)
+
+var (
+	ErrCustomIDVersion   = inputError("botctx.component.version")
+	ErrCustomIDSignature = inputError("botctx.component.signature")
+	ErrCustomIDMalformed = inputError("botctx.component.malformed")
+	ErrCustomIDUnknown   = inputError("botctx.component.unknown")
+)
+
+var customIDEscaper = strings.NewReplacer("%", "%25", ";", "%3B")
+
+// EncodeCustomID builds the custom ID of a component routed to route, with
+// the exported fields of payload as arguments. payload may be nil.
+func EncodeCustomID(route string, payload any) (string, error) {
+	parts := []string{CustomIDVersion, customIDEscaper.Replace(route)}
+
+	if payload != nil {
+		value := reflect.Indirect(reflect.ValueOf(payload))
+		for idx := 0; idx < value.NumField(); idx++ {
+			if !value.Type().Field(idx).IsExported() {
+				continue
+			}
+			str, err := formatCustomIDField(value.Field(idx))
+			if err != nil {
+				return "", fmt.Errorf("custom id %q: %v: %w", route, value.Type().Field(idx).Name, err)
+			}
+			parts = append(parts, customIDEscaper.Replace(str))
+		}
+	}
+
+	id := strings.Join(parts, ";")
+	if cfg.Interactions.CustomIDSecret != "" {
+		id += ";" + signCustomID(id)
+	}
+
+	if len(id) > CustomIDMaxLength {
+		return "", fmt.Errorf("custom id %q is %v characters long, discord allows %v", id, len(id), CustomIDMaxLength)
+	}
+	return id, nil
+}
+
+// CustomID is EncodeCustomID for payloads which are known to fit, it panics
+// otherwise.
+func CustomID(route string, payload any) string {
+	id, err := EncodeCustomID(route, payload)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// DecodeCustomID checks the version and signature of id and returns its
+// route and unescaped arguments.
+func DecodeCustomID(id string) (string, []string, error) {
+	parts := strings.Split(id, ";")
+	if parts[0] != CustomIDVersion {
+		return "", nil, ErrCustomIDVersion
+	}
+	if len(parts) < 2 {
+		return "", nil, ErrCustomIDMalformed
+	}
+
+	if cfg.Interactions.CustomIDSecret != "" {
+		if len(parts) < 3 {
+			return "", nil, ErrCustomIDSignature
+		}
+		signed := strings.Join(parts[:len(parts)-1], ";")
+		if !hmac.Equal([]byte(parts[len(parts)-1]), []byte(signCustomID(signed))) {
+			return "", nil, ErrCustomIDSignature
+		}
+		parts = parts[:len(parts)-1]
+	}
+
+	for idx, part := range parts {
+		str, err := unescapeCustomID(part)
+		if err != nil {
+			return "", nil, ErrCustomIDMalformed
+		}
+		parts[idx] = str
+	}
+
+	return parts[1], parts[2:], nil
+}
+
+func signCustomID(id string) string {
+	mac := hmac.New(sha256.New, []byte(cfg.Interactions.CustomIDSecret))
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:customIDSignatureLength])
+}
+
+func unescapeCustomID(str string) (string, error) {
+	if !strings.Contains(str, "%") {
+		return str, nil
+	}
+
+	var builder strings.Builder
+	for idx := 0; idx < len(str); idx++ {
+		if str[idx] != '%' {
+			builder.WriteByte(str[idx])
+			continue
+		}
+		if idx+2 >= len(str) {
+			return "", ErrCustomIDMalformed
+		}
+		switch str[idx+1 : idx+3] {
+		case "25":
+			builder.WriteByte('%')
+		case "3B":
+			builder.WriteByte(';')
+		default:
+			return "", ErrCustomIDMalformed
+		}
+		idx += 2
+	}
+	return builder.String(), nil
+}
+
+func formatCustomIDField(field reflect.Value) (string, error) {
+	switch field.Kind() {
+	case reflect.String:
+		return field.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(field.Uint(), 10), nil
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), nil
+	}
+	return "", fmt.Errorf("unsupported type %v", field.Type())
+}
+
+func parseCustomIDField(field reflect.Value, str string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(str)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(str, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(str, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %v", field.Type())
+	}
+	return nil
+}
+
+func decodeCustomIDArgs(args []string, out any) error {
+	value := reflect.ValueOf(out).Elem()
+
+	fields := make([]int, 0, value.NumField())
+	for idx := 0; idx < value.NumField(); idx++ {
+		if value.Type().Field(idx).IsExported() {
+			fields = append(fields, idx)
+		}
+	}
+	if len(fields) != len(args) {
+		return ErrCustomIDMalformed
+	}
+
+	for idx, field := range fields {
+		err := parseCustomIDField(value.Field(field), args[idx])
+		if err != nil {
+			return ErrCustomIDMalformed
+		}
+	}
+	return nil
+}
+
+// BindComponent decodes the arguments of a component's custom ID into the
+// payload it was encoded from before calling fn.
+func BindComponent[T any](fn func(ctx context.Context, session Session, i *discordgo.InteractionCreate, payload *T)) InteractionFunc {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := reflect.New(t.Field(idx).Type).Elem()
+		if _, err := formatCustomIDField(field); t.Field(idx).IsExported() && err != nil {
+			log.Fatalf("error: BindComponent: field %v of %v: %v", t.Field(idx).Name, t, err)
+		}
+	}
+
+	return func(ctx context.Context, session Session, i *discordgo.InteractionCreate, args []string) {
+		var payload T
+		err := decodeCustomIDArgs(args, &payload)
+		if err != nil {
+			respondCustomIDError(ctx, err)
+			return
+		}
+		fn(ctx, session, i, &payload)
+	}
+}
+
+// customIDError is the error reported for an id which decoded to a route
+// without a handler.
+func customIDError(err error) error {
+	if err == nil {
+		return ErrCustomIDUnknown
+	}
+	return err
+}
+
+func respondCustomIDError(ctx context.Context, err error) {
+	respondError(ctx, Tr(ctx, "botctx.component.title"), Tr(ctx, "botctx.component.description", localizeInput(ctx, err)))
+}
@@ -0,0 +1,177 @@
+package botctx
+
+import (
+	"Raku/config"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testPayload struct {
+	Name   string
+	Page   int
+	Unread uint8
+	Open   bool
+	hidden string
+}
+
+func withSecret(t *testing.T, secret string) {
+	withConfig(t, func(conf *config.Config) {
+		conf.Interactions.CustomIDSecret = secret
+	})
+}
+
+func TestCustomIDRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		route   string
+		payload any
+		want    []string
+	}{
+		{"no payload", "", "help", nil, []string{}},
+		{"fields", "", "anime seasonal", &testPayload{Name: "frieren", Page: -2, Unread: 7, Open: true, hidden: "x"}, []string{"frieren", "-2", "7", "true"}},
+		{"separators", "", "a;b", testPayload{Name: "50%;off;%3B"}, []string{"50%;off;%3B", "0", "0", "false"}},
+		{"signed", "secret", "anime seasonal", &testPayload{Name: "frieren", Page: 3}, []string{"frieren", "3", "0", "false"}},
+		{"signed separators", "secret", "help", &testPayload{Name: ";;%"}, []string{";;%", "0", "0", "false"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withSecret(t, test.secret)
+
+			id, err := EncodeCustomID(test.route, test.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(id, CustomIDVersion+";") {
+				t.Errorf("%q does not start with the version", id)
+			}
+
+			route, args, err := DecodeCustomID(id)
+			if err != nil {
+				t.Fatalf("decoding %q: %v", id, err)
+			}
+			if route != test.route || !reflect.DeepEqual(args, test.want) {
+				t.Errorf("decoded %q %q, want %q %q", route, args, test.route, test.want)
+			}
+
+			if test.payload == nil {
+				return
+			}
+			var decoded testPayload
+			err = decodeCustomIDArgs(args, &decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := reflect.Indirect(reflect.ValueOf(test.payload)).Interface().(testPayload)
+			want.hidden = ""
+			if decoded != want {
+				t.Errorf("payload %+v, want %+v", decoded, want)
+			}
+		})
+	}
+}
+
+func TestDecodeCustomIDErrors(t *testing.T) {
+	withSecret(t, "secret")
+	signed := CustomID("anime seasonal", &testPayload{Name: "frieren", Page: 3})
+	cut := strings.LastIndex(signed, ";")
+
+	tests := []struct {
+		name   string
+		secret string
+		id     string
+		err    error
+	}{
+		{"older version", "", "v0;anime seasonal;3", ErrCustomIDVersion},
+		{"no version", "", "anime seasonal", ErrCustomIDVersion},
+		{"no route", "", "v1", ErrCustomIDMalformed},
+		{"bad escape", "", "v1;help;50%", ErrCustomIDMalformed},
+		{"unknown escape", "", "v1;help;%41", ErrCustomIDMalformed},
+		{"lowercase escape", "", "v1;help;%3b", ErrCustomIDMalformed},
+		{"missing signature", "secret", "v1;help", ErrCustomIDSignature},
+		{"unsigned", "secret", signed[:cut], ErrCustomIDSignature},
+		{"tampered payload", "secret", strings.Replace(signed, "frieren", "frieran", 1), ErrCustomIDSignature},
+		{"tampered signature", "secret", signed[:cut+1] + strings.Repeat("A", customIDSignatureLength+3), ErrCustomIDSignature},
+		{"other secret", "other", signed, ErrCustomIDSignature},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withSecret(t, test.secret)
+			_, _, err := DecodeCustomID(test.id)
+			if !errors.Is(err, test.err) {
+				t.Errorf("decoding %q: %v, want %v", test.id, err, test.err)
+			}
+		})
+	}
+}
+
+func TestDecodeCustomIDArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  error
+	}{
+		{"valid", []string{"frieren", "3", "255", "true"}, nil},
+		{"too few", []string{"frieren", "3", "1"}, ErrCustomIDMalformed},
+		{"too many", []string{"frieren", "3", "1", "true", "x"}, ErrCustomIDMalformed},
+		{"not a number", []string{"frieren", "three", "1", "true"}, ErrCustomIDMalformed},
+		{"overflow", []string{"frieren", "3", "256", "true"}, ErrCustomIDMalformed},
+		{"not a bool", []string{"frieren", "3", "1", "yes"}, ErrCustomIDMalformed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var payload testPayload
+			err := decodeCustomIDArgs(test.args, &payload)
+			if !errors.Is(err, test.err) {
+				t.Errorf("%v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestEncodeCustomIDLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		size   int
+		fits   bool
+	}{
+		// "v1;r;" leaves 95 characters for the payload.
+		{"at the limit", "", 95, true},
+		{"over the limit", "", 96, false},
+		// The signature takes 1+12 of them.
+		{"signed at the limit", "secret", 82, true},
+		{"signed over the limit", "secret", 83, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withSecret(t, test.secret)
+			id, err := EncodeCustomID("r", struct{ Name string }{strings.Repeat("x", test.size)})
+			if test.fits && (err != nil || len(id) != CustomIDMaxLength) {
+				t.Errorf("%q: %v, want an id of %v characters", id, err, CustomIDMaxLength)
+			}
+			if !test.fits && err == nil {
+				t.Errorf("encoded %v characters", len(id))
+			}
+		})
+	}
+
+	// Escaping counts towards the limit.
+	_, err := EncodeCustomID("r", struct{ Name string }{strings.Repeat(";", 32)})
+	if err == nil {
+		t.Error("escaped payload over the limit was encoded")
+	}
+}
+
+func TestEncodeCustomIDUnsupported(t *testing.T) {
+	_, err := EncodeCustomID("r", struct{ Value float64 }{1})
+	if err == nil {
+		t.Error("float field was encoded")
+	}
+}
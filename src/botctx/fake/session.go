@@ -0,0 +1,234 @@
+// Package fake provides an in-memory botctx.Session that records what
+// handlers send, so they can run without discord.
+package fake
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"Raku/botctx"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var ErrNotFound = errors.New("fake: not found")
+
+// Execution is a message posted through a webhook.
+type Execution struct {
+	WebhookID string
+	ThreadID  string
+	Params    *discordgo.WebhookParams
+}
+
+// Session serves channels, messages and permissions from its fields and
+// records every response. Fields may be set up before use. Errors maps a
+// method name such as "WebhookExecute" to an error it should return.
+type Session struct {
+	mutex sync.Mutex
+
+	User   *discordgo.User
+	Client *http.Client
+
+	Channels map[string]*discordgo.Channel
+	// Messages of a channel, newest first as discord returns them.
+	Messages map[string][]*discordgo.Message
+	// Permissions by user id and then channel id.
+	Permissions map[string]map[string]int64
+	Errors      map[string]error
+
+	Responses []*discordgo.InteractionResponse
+	Edits     []*discordgo.WebhookEdit
+	Followups []*discordgo.WebhookParams
+	// ResponseDeletes counts the deletions of the original response.
+	ResponseDeletes int
+
+	Webhooks   map[string]*discordgo.Webhook
+	Executions []Execution
+	Deleted    []string
+
+	nextID int
+}
+
+var _ botctx.Session = (*Session)(nil)
+
+func New() *Session {
+	return &Session{
+		User:        &discordgo.User{ID: "1", Username: "Raku", Bot: true},
+		Client:      http.DefaultClient,
+		Channels:    make(map[string]*discordgo.Channel),
+		Messages:    make(map[string][]*discordgo.Message),
+		Permissions: make(map[string]map[string]int64),
+		Errors:      make(map[string]error),
+		Webhooks:    make(map[string]*discordgo.Webhook),
+	}
+}
+
+func (s *Session) id() string {
+	s.nextID++
+	return strconv.Itoa(1000 + s.nextID)
+}
+
+func (s *Session) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["InteractionRespond"]; err != nil {
+		return err
+	}
+	s.Responses = append(s.Responses, resp)
+	return nil
+}
+
+func (s *Session) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["InteractionResponseEdit"]; err != nil {
+		return nil, err
+	}
+	s.Edits = append(s.Edits, newresp)
+	return &discordgo.Message{ID: "@original", ChannelID: interaction.ChannelID}, nil
+}
+
+func (s *Session) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["InteractionResponseDelete"]; err != nil {
+		return err
+	}
+	s.ResponseDeletes++
+	return nil
+}
+
+func (s *Session) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["FollowupMessageCreate"]; err != nil {
+		return nil, err
+	}
+	s.Followups = append(s.Followups, data)
+	return &discordgo.Message{ID: s.id(), ChannelID: interaction.ChannelID}, nil
+}
+
+func (s *Session) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["Channel"]; err != nil {
+		return nil, err
+	}
+	channel, ok := s.Channels[channelID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return channel, nil
+}
+
+// ChannelMessages pages through Messages by beforeID like discord does.
+// afterID and aroundID are not supported.
+func (s *Session) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["ChannelMessages"]; err != nil {
+		return nil, err
+	}
+
+	msgs := s.Messages[channelID]
+	start := 0
+	if beforeID != "" {
+		start = len(msgs)
+		for idx, msg := range msgs {
+			if msg.ID == beforeID {
+				start = idx + 1
+				break
+			}
+		}
+	}
+
+	end := start + limit
+	if end > len(msgs) {
+		end = len(msgs)
+	}
+	return append([]*discordgo.Message(nil), msgs[start:end]...), nil
+}
+
+func (s *Session) UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["UserChannelPermissions"]; err != nil {
+		return 0, err
+	}
+	return s.Permissions[userID][channelID], nil
+}
+
+func (s *Session) WebhookCreate(channelID, name, avatar string, options ...discordgo.RequestOption) (*discordgo.Webhook, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["WebhookCreate"]; err != nil {
+		return nil, err
+	}
+	webhook := &discordgo.Webhook{
+		ID:        s.id(),
+		Type:      discordgo.WebhookTypeIncoming,
+		ChannelID: channelID,
+		Name:      name,
+		Avatar:    avatar,
+		Token:     "token",
+	}
+	s.Webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+func (s *Session) WebhookDelete(webhookID string, options ...discordgo.RequestOption) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["WebhookDelete"]; err != nil {
+		return err
+	}
+	if _, ok := s.Webhooks[webhookID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.Webhooks, webhookID)
+	s.Deleted = append(s.Deleted, webhookID)
+	return nil
+}
+
+func (s *Session) WebhookExecute(webhookID, token string, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.WebhookThreadExecute(webhookID, token, wait, "", data, options...)
+}
+
+func (s *Session) WebhookThreadExecute(webhookID, token string, wait bool, threadID string, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Errors["WebhookExecute"]; err != nil {
+		return nil, err
+	}
+	webhook, ok := s.Webhooks[webhookID]
+	if !ok || webhook.Token != token {
+		return nil, ErrNotFound
+	}
+	s.Executions = append(s.Executions, Execution{WebhookID: webhookID, ThreadID: threadID, Params: data})
+
+	channelID := webhook.ChannelID
+	if threadID != "" {
+		channelID = threadID
+	}
+	return &discordgo.Message{ID: s.id(), ChannelID: channelID, Content: data.Content}, nil
+}
+
+func (s *Session) HTTPClient() *http.Client {
+	return s.Client
+}
+
+func (s *Session) BotUser() *discordgo.User {
+	return s.User
+}
@@ -0,0 +1,400 @@
+package botctx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const helpCommandName = "help"
+
+type helpArgs struct {
+	Command string `option:"command" description:"command to show the usage of" max:"100"`
+}
+
+// HelpCommand lists the registered commands and shows the usage of one of
+// them. Register it like any other command.
+var HelpCommand = CommandDesc{
+	Name:        helpCommandName,
+	Description: "List the commands of the bot or show how to use one",
+	Options:     OptionsOf[helpArgs](),
+	Func:        Bind(help),
+	Interaction: helpSelect,
+	Autocomplete: map[string]AutocompleteFunc{
+		"command": helpAutocomplete,
+	},
+	Examples: []string{"/help", "/help command:anime search"},
+}
+
+// optionDefaults holds the `default` tags of options, which discord has no
+// field for.
+var optionDefaults = make(map[*discordgo.ApplicationCommandOption]string)
+
+var optionTypeKeys = map[discordgo.ApplicationCommandOptionType]string{
+	discordgo.ApplicationCommandOptionString:      "string",
+	discordgo.ApplicationCommandOptionInteger:     "integer",
+	discordgo.ApplicationCommandOptionBoolean:     "boolean",
+	discordgo.ApplicationCommandOptionUser:        "user",
+	discordgo.ApplicationCommandOptionChannel:     "channel",
+	discordgo.ApplicationCommandOptionRole:        "role",
+	discordgo.ApplicationCommandOptionMentionable: "mentionable",
+	discordgo.ApplicationCommandOptionNumber:      "number",
+	discordgo.ApplicationCommandOptionAttachment:  "attachment",
+}
+
+// helpEntry is a command which can be invoked, a chat input command without
+// subcommands or a context menu command.
+type helpEntry struct {
+	kind        discordgo.ApplicationCommandType
+	path        []string
+	description string
+	options     []*discordgo.ApplicationCommandOption
+	cmd         Command
+}
+
+func (e helpEntry) key() string {
+	return strings.Join(e.path, " ")
+}
+
+// localeKey is the prefix of the translations of e, see LoadLocales.
+func (e helpEntry) localeKey() string {
+	return "commands." + strings.Join(e.path, ".")
+}
+
+func helpEntries() []helpEntry {
+	entries := make([]helpEntry, 0, len(commandLUT)+len(menuLUT))
+
+	var collect func(path []string, description string, options []*discordgo.ApplicationCommandOption)
+	collect = func(path []string, description string, options []*discordgo.ApplicationCommandOption) {
+		leaf := true
+		for _, option := range options {
+			if option.Type == discordgo.ApplicationCommandOptionSubCommand || option.Type == discordgo.ApplicationCommandOptionSubCommandGroup {
+				leaf = false
+				collect(append(path[:len(path):len(path)], option.Name), option.Description, option.Options)
+			}
+		}
+		if leaf {
+			entries = append(entries, helpEntry{
+				kind:        discordgo.ChatApplicationCommand,
+				path:        path,
+				description: description,
+				options:     options,
+			})
+		}
+	}
+	for _, cmd := range commandLUT {
+		collect([]string{cmd.Command.Name}, cmd.Command.Description, cmd.Command.Options)
+	}
+	for _, cmd := range menuLUT {
+		entries = append(entries, helpEntry{kind: cmd.Command.Type, path: []string{cmd.Command.Name}})
+	}
+
+	for idx := range entries {
+		entries[idx].cmd, _ = commandByPath(entries[idx].key())
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].kind != entries[b].kind {
+			return entries[a].kind < entries[b].kind
+		}
+		return entries[a].key() < entries[b].key()
+	})
+	return entries
+}
+
+func findHelpEntry(key string) (helpEntry, bool) {
+	key = strings.Join(strings.Fields(strings.TrimPrefix(key, "/")), " ")
+	for _, entry := range helpEntries() {
+		if strings.EqualFold(entry.key(), key) {
+			return entry, true
+		}
+	}
+	return helpEntry{}, false
+}
+
+// helpName is e as it is shown in the client of locale, e.g. "/anime search".
+func helpName(locale discordgo.Locale, e helpEntry) string {
+	if e.kind != discordgo.ChatApplicationCommand {
+		return translateOr(locale, e.localeKey()+".name", e.path[0])
+	}
+
+	names := make([]string, len(e.path))
+	for idx, name := range e.path {
+		names[idx] = translateOr(locale, "commands."+strings.Join(e.path[:idx+1], ".")+".name", name)
+	}
+	return "/" + strings.Join(names, " ")
+}
+
+func helpOptionName(locale discordgo.Locale, e helpEntry, option *discordgo.ApplicationCommandOption) string {
+	return translateOr(locale, e.localeKey()+".options."+option.Name+".name", option.Name)
+}
+
+func helpUsage(locale discordgo.Locale, e helpEntry) string {
+	var builder strings.Builder
+	builder.WriteString(helpName(locale, e))
+	for _, option := range e.options {
+		if option.Required {
+			fmt.Fprintf(&builder, " <%v>", helpOptionName(locale, e, option))
+		} else {
+			fmt.Fprintf(&builder, " [%v]", helpOptionName(locale, e, option))
+		}
+	}
+	return builder.String()
+}
+
+func helpOption(locale discordgo.Locale, e helpEntry, option *discordgo.ApplicationCommandOption) string {
+	key := e.localeKey() + ".options." + option.Name
+
+	required := Translate(locale, "botctx.help.optional")
+	if option.Required {
+		required = Translate(locale, "botctx.help.required")
+	}
+	lines := []string{fmt.Sprintf("`%v` · %v · %v", helpOptionName(locale, e, option), Translate(locale, "botctx.help.types."+optionTypeKeys[option.Type]), required)}
+
+	if desc := translateOr(locale, key+".description", option.Description); desc != "" {
+		lines = append(lines, desc)
+	}
+
+	details := make([]string, 0, 4)
+	if str, ok := optionDefaults[option]; ok {
+		details = append(details, Translate(locale, "botctx.help.default", translateOr(locale, key+".default", str)))
+	}
+	if option.MinLength != nil {
+		details = append(details, Translate(locale, "botctx.help.min_length", *option.MinLength))
+	}
+	max, hasMax := optionMaxima[option]
+	if hasMax && option.Type == discordgo.ApplicationCommandOptionString {
+		details = append(details, Translate(locale, "botctx.help.max_length", int(max)))
+	}
+	if option.MinValue != nil {
+		details = append(details, Translate(locale, "botctx.help.min", *option.MinValue))
+	}
+	if hasMax && option.Type != discordgo.ApplicationCommandOptionString {
+		details = append(details, Translate(locale, "botctx.help.max", max))
+	}
+	if len(option.Choices) > 0 {
+		choices := make([]string, len(option.Choices))
+		for idx, choice := range option.Choices {
+			choices[idx] = "`" + translateOr(locale, key+".choices."+choice.Name, choice.Name) + "`"
+		}
+		details = append(details, Translate(locale, "botctx.help.choices", strings.Join(choices, ", ")))
+	}
+	if len(details) > 0 {
+		lines = append(lines, strings.Join(details, " · "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func helpCooldown(locale discordgo.Locale, cmd Command) string {
+	cooldown := cmd.Cooldown
+	if cooldown.Scope == "" {
+		return ""
+	}
+	scope := Translate(locale, "botctx.help.scopes."+cooldown.Scope)
+
+	lines := make([]string, 0, 2)
+	if cooldown.Duration > 0 {
+		lines = append(lines, Translate(locale, "botctx.help.cooldown_duration", cooldown.Duration, scope))
+	}
+	if cooldown.Concurrency > 0 {
+		lines = append(lines, Translate(locale, "botctx.help.cooldown_concurrency", cooldown.Concurrency, scope))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func helpSelectMenu(locale discordgo.Locale, entries []helpEntry, selected string) discordgo.MessageComponent {
+	if len(entries) > 25 {
+		entries = entries[:25]
+	}
+
+	options := make([]discordgo.SelectMenuOption, 0, len(entries))
+	for _, entry := range entries {
+		desc := translateOr(locale, entry.localeKey()+".description", entry.description)
+		if entry.kind != discordgo.ChatApplicationCommand {
+			desc = Translate(locale, helpMenuKey(entry.kind))
+		}
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       Truncate(helpName(locale, entry), 100),
+			Value:       entry.key(),
+			Description: Truncate(desc, 100),
+			Default:     entry.key() == selected,
+		})
+	}
+
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				MenuType:    discordgo.StringSelectMenu,
+				CustomID:    CustomID(ComponentID(helpCommandName), nil),
+				Placeholder: Translate(locale, "botctx.help.select"),
+				Options:     options,
+			},
+		},
+	}
+}
+
+func helpList(locale discordgo.Locale) *discordgo.InteractionResponseData {
+	entries := helpEntries()
+
+	lines := make([]string, 0, len(entries)+2)
+	menus := false
+	for _, entry := range entries {
+		if entry.kind == discordgo.ChatApplicationCommand {
+			lines = append(lines, fmt.Sprintf("`%v` — %v", helpName(locale, entry), translateOr(locale, entry.localeKey()+".description", entry.description)))
+			continue
+		}
+		if !menus {
+			lines = append(lines, "", Translate(locale, "botctx.help.menus"))
+			menus = true
+		}
+		lines = append(lines, fmt.Sprintf("`%v` — %v", helpName(locale, entry), Translate(locale, helpMenuKey(entry.kind))))
+	}
+	if cfg.TextCommands.Prefix != "" {
+		lines = append(lines, "", Translate(locale, "botctx.help.text", cfg.TextCommands.Prefix))
+	}
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title:       Translate(locale, "botctx.help.title"),
+				Description: Truncate(strings.Join(lines, "\n"), 4096),
+				Type:        discordgo.EmbedTypeRich,
+				Color:       cfg.Colors.Info,
+				Footer:      &discordgo.MessageEmbedFooter{Text: Translate(locale, "botctx.help.footer")},
+			},
+		},
+		Components: []discordgo.MessageComponent{helpSelectMenu(locale, entries, "")},
+		Flags:      discordgo.MessageFlagsEphemeral,
+	}
+}
+
+func helpMenuKey(kind discordgo.ApplicationCommandType) string {
+	if kind == discordgo.UserApplicationCommand {
+		return "botctx.help.menu_user"
+	}
+	return "botctx.help.menu_message"
+}
+
+func helpDetail(locale discordgo.Locale, entry helpEntry) *discordgo.InteractionResponseData {
+	embed := &discordgo.MessageEmbed{
+		Title:       helpName(locale, entry),
+		Description: translateOr(locale, entry.localeKey()+".description", entry.description),
+		Type:        discordgo.EmbedTypeRich,
+		Color:       cfg.Colors.Info,
+	}
+
+	if entry.kind == discordgo.ChatApplicationCommand {
+		usage := "`" + helpUsage(locale, entry) + "`"
+		if cfg.TextCommands.Prefix != "" {
+			usage += "\n`" + textCommand{path: entry.path, options: entry.options}.usage() + "`"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: Translate(locale, "botctx.help.usage"), Value: usage})
+	} else {
+		embed.Description = Translate(locale, helpMenuKey(entry.kind))
+	}
+
+	if len(entry.options) > 0 {
+		options := make([]string, len(entry.options))
+		for idx, option := range entry.options {
+			options[idx] = helpOption(locale, entry, option)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: Translate(locale, "botctx.help.options"), Value: Truncate(strings.Join(options, "\n\n"), 1024)})
+	}
+
+	perms := make([]string, 0, 2)
+	if entry.cmd.MemberPermissions != 0 {
+		perms = append(perms, Translate(locale, "botctx.help.member_permissions", LocalizedPermissionNames(locale, entry.cmd.MemberPermissions)))
+	}
+	if entry.cmd.BotPermissions != 0 {
+		perms = append(perms, Translate(locale, "botctx.help.bot_permissions", LocalizedPermissionNames(locale, entry.cmd.BotPermissions)))
+	}
+	if len(perms) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: Translate(locale, "botctx.help.permissions"), Value: strings.Join(perms, "\n")})
+	}
+
+	if cooldown := helpCooldown(locale, entry.cmd); cooldown != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: Translate(locale, "botctx.help.cooldown"), Value: cooldown})
+	}
+
+	if len(entry.cmd.Examples) > 0 {
+		examples := make([]string, len(entry.cmd.Examples))
+		for idx, example := range entry.cmd.Examples {
+			examples[idx] = "`" + example + "`"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: Translate(locale, "botctx.help.examples"), Value: Truncate(strings.Join(examples, "\n"), 1024)})
+	}
+
+	return &discordgo.InteractionResponseData{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: []discordgo.MessageComponent{helpSelectMenu(locale, helpEntries(), entry.key())},
+		Flags:      discordgo.MessageFlagsEphemeral,
+	}
+}
+
+func help(ctx context.Context, session Session, i *discordgo.InteractionCreate, args *helpArgs) {
+	locale := Locale(ctx)
+
+	data := helpList(locale)
+	if args.Command != "" {
+		entry, ok := findHelpEntry(args.Command)
+		if !ok {
+			respondError(ctx, Tr(ctx, "botctx.option.title"), Tr(ctx, "botctx.help.unknown", args.Command))
+			return
+		}
+		data = helpDetail(locale, entry)
+	}
+
+	err := ResponderFrom(ctx).Respond(&discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "help", "err", err)
+	}
+}
+
+func helpSelect(ctx context.Context, session Session, i *discordgo.InteractionCreate, args []string) {
+	values := i.MessageComponentData().Values
+	if len(values) == 0 {
+		return
+	}
+	entry, ok := findHelpEntry(values[0])
+	if !ok {
+		respondCustomIDError(ctx, ErrCustomIDUnknown)
+		return
+	}
+
+	err := ResponderFrom(ctx).Respond(&discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: helpDetail(Locale(ctx), entry),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "helpSelect", "err", err)
+	}
+}
+
+func helpAutocomplete(ctx context.Context, session Session, i *discordgo.InteractionCreate, option *discordgo.ApplicationCommandInteractionDataOption) []*discordgo.ApplicationCommandOptionChoice {
+	locale := Locale(ctx)
+	search := strings.ToLower(strings.TrimSpace(option.StringValue()))
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, 25)
+	for _, entry := range helpEntries() {
+		if len(choices) == 25 {
+			break
+		}
+		name := helpName(locale, entry)
+		if !strings.Contains(strings.ToLower(name), search) && !strings.Contains(strings.ToLower(entry.key()), search) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  Truncate(name, 100),
+			Value: entry.key(),
+		})
+	}
+	return choices
+}
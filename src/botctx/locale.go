@@ -0,0 +1,237 @@
+package botctx
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-yaml/yaml"
+)
+
+// DefaultLocale is used when an interaction has no locale or a string has
+// no translation for it.
+const DefaultLocale = discordgo.EnglishUS
+
+// Locale files are yaml named after a discord locale, e.g. "ja.yaml". Nested
+// keys are joined with dots, so the keys
+//
+//	commands:
+//	  anime:
+//	    search:
+//	      description: アニメを検索
+//
+// make "commands.anime.search.description".
+//
+// Commands are localized from "commands.<path>.name" and
+// "commands.<path>.description", their options from
+// "commands.<path>.options.<option>.name" and ".description" and option
+// choices from "commands.<path>.options.<option>.choices.<choice name>".
+// /help shows "commands.<path>.options.<option>.default" in place of the
+// option's default tag.
+
+//go:embed locales/*.yaml
+var builtinLocales embed.FS
+
+var catalog = make(map[discordgo.Locale]map[string]string)
+
+func init() {
+	fsys, err := fs.Sub(builtinLocales, "locales")
+	if err == nil {
+		err = LoadLocales(fsys)
+	}
+	if err != nil {
+		log.Fatalf("error: botctx locales: %+v", err)
+	}
+}
+
+// LoadLocales adds the strings of the locale files at the root of fsys to the
+// catalog, replacing strings already loaded under the same key.
+func LoadLocales(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.yaml")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		locale := discordgo.Locale(strings.TrimSuffix(file, ".yaml"))
+		if _, ok := discordgo.Locales[locale]; !ok {
+			return fmt.Errorf("locale file %v: unknown locale %q", file, locale)
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+
+		var tree map[string]interface{}
+		err = yaml.Unmarshal(content, &tree)
+		if err != nil {
+			return fmt.Errorf("locale file %v: %w", file, err)
+		}
+
+		if catalog[locale] == nil {
+			catalog[locale] = make(map[string]string)
+		}
+		flattenLocale(catalog[locale], "", tree)
+	}
+	return nil
+}
+
+func flattenLocale(out map[string]string, prefix string, value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, sub := range value {
+			flattenLocale(out, prefix+key+".", sub)
+		}
+	case map[interface{}]interface{}:
+		for key, sub := range value {
+			flattenLocale(out, prefix+fmt.Sprint(key)+".", sub)
+		}
+	case nil:
+	default:
+		out[strings.TrimSuffix(prefix, ".")] = fmt.Sprint(value)
+	}
+}
+
+func lookup(locale discordgo.Locale, key string) (string, bool) {
+	str, ok := catalog[locale][key]
+	return str, ok
+}
+
+// Translate returns the string of key in locale, falling back to the
+// default locale and then to key itself. args are formatted into it like
+// fmt.Sprintf.
+func Translate(locale discordgo.Locale, key string, args ...any) string {
+	str, ok := lookup(locale, key)
+	if !ok {
+		str, ok = lookup(DefaultLocale, key)
+	}
+	if !ok {
+		str = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(str, args...)
+	}
+	return str
+}
+
+// translateOr is the string of key in locale, or fallback. Commands are
+// written in the default locale in code, so their strings fall back to it.
+func translateOr(locale discordgo.Locale, key string, fallback string) string {
+	if str, ok := lookup(locale, key); ok {
+		return str
+	}
+	return fallback
+}
+
+// InteractionLocale is the language of the user of i, or else of its guild.
+func InteractionLocale(i *discordgo.Interaction) discordgo.Locale {
+	if i == nil {
+		return DefaultLocale
+	}
+	if i.Locale != "" {
+		return i.Locale
+	}
+	if i.GuildLocale != nil && *i.GuildLocale != "" {
+		return *i.GuildLocale
+	}
+	return DefaultLocale
+}
+
+// GuildLocale is the language of the guild of i, for messages everyone in it
+// reads rather than just the user.
+func GuildLocale(i *discordgo.Interaction) discordgo.Locale {
+	if i != nil && i.GuildLocale != nil && *i.GuildLocale != "" {
+		return *i.GuildLocale
+	}
+	return DefaultLocale
+}
+
+// Locale is the locale of the interaction ctx was created for.
+func Locale(ctx context.Context) discordgo.Locale {
+	if r := ResponderFrom(ctx); r != nil {
+		return InteractionLocale(r.interaction)
+	}
+	return DefaultLocale
+}
+
+// Tr translates key for the interaction ctx was created for.
+func Tr(ctx context.Context, key string, args ...any) string {
+	return Translate(Locale(ctx), key, args...)
+}
+
+// InputError is a mistake in what a user sent, worded by the catalog string
+// of Key with Args formatted into it.
+type InputError struct {
+	Key  string
+	Args []any
+}
+
+func inputError(key string, args ...any) *InputError {
+	return &InputError{Key: key, Args: args}
+}
+
+func (e *InputError) Error() string {
+	return e.Localize(DefaultLocale)
+}
+
+func (e *InputError) Localize(locale discordgo.Locale) string {
+	return Translate(locale, e.Key, e.Args...)
+}
+
+// localizeInput is the message of err in the locale of ctx, errors which are
+// not an InputError stay as they are.
+func localizeInput(ctx context.Context, err error) string {
+	var ierr *InputError
+	if errors.As(err, &ierr) {
+		return ierr.Localize(Locale(ctx))
+	}
+	return err.Error()
+}
+
+// localizations collects the translations of key in every locale but the
+// default one, which is what the command itself is written in.
+func localizations(key string) map[discordgo.Locale]string {
+	res := make(map[discordgo.Locale]string)
+	for locale, strs := range catalog {
+		if str, ok := strs[key]; ok && locale != DefaultLocale {
+			res[locale] = str
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+func localizeCommand(cmd *discordgo.ApplicationCommand) {
+	key := "commands." + cmd.Name
+	if names := localizations(key + ".name"); names != nil {
+		cmd.NameLocalizations = &names
+	}
+	if descs := localizations(key + ".description"); descs != nil {
+		cmd.DescriptionLocalizations = &descs
+	}
+	localizeOptions(key, cmd.Options)
+}
+
+func localizeOptions(prefix string, options []*discordgo.ApplicationCommandOption) {
+	for _, option := range options {
+		key := prefix + ".options." + option.Name
+		if option.Type == discordgo.ApplicationCommandOptionSubCommand || option.Type == discordgo.ApplicationCommandOptionSubCommandGroup {
+			key = prefix + "." + option.Name
+		}
+
+		option.NameLocalizations = localizations(key + ".name")
+		option.DescriptionLocalizations = localizations(key + ".description")
+		for _, choice := range option.Choices {
+			choice.NameLocalizations = localizations(key + ".choices." + choice.Name)
+		}
+		localizeOptions(key, option.Options)
+	}
+}
@@ -0,0 +1,55 @@
+package botctx
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestInputErrorLocalized(t *testing.T) {
+	var args struct {
+		Name string `option:"name" required:"true"`
+	}
+	var form struct {
+		Page int `modal:"page"`
+	}
+	modal := discordgo.ModalSubmitInteractionData{Components: []discordgo.MessageComponent{
+		&discordgo.ActionsRow{Components: []discordgo.MessageComponent{&discordgo.TextInput{CustomID: "page", Value: "two"}}},
+	}}
+
+	_, quoteErr := splitTextArgs(`"frieren`)
+	_, _, versionErr := DecodeCustomID("v0;route")
+
+	tests := []struct {
+		name string
+		err  error
+		key  string
+	}{
+		{"option", bindOptions(discordgo.ApplicationCommandInteractionData{}, optionSpecs(reflect.TypeOf(args)), reflect.ValueOf(&args).Elem()), "botctx.option.required"},
+		{"modal", decodeModal(modal, &form), "botctx.input.number"},
+		{"text", quoteErr, "botctx.text.quote"},
+		{"custom id", versionErr, "botctx.component.version"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ierr *InputError
+			if !errors.As(test.err, &ierr) {
+				t.Fatalf("%v is not an InputError", test.err)
+			}
+			if ierr.Key != test.key {
+				t.Errorf("key is %v, want %v", ierr.Key, test.key)
+			}
+			for _, locale := range []discordgo.Locale{discordgo.EnglishUS, discordgo.Japanese} {
+				if _, ok := lookup(locale, ierr.Key); !ok {
+					t.Errorf("%v has no translation in %v", ierr.Key, locale)
+				}
+			}
+			if en, ja := ierr.Localize(discordgo.EnglishUS), ierr.Localize(discordgo.Japanese); en == ja {
+				t.Errorf("%q is not translated", en)
+			}
+		})
+	}
+}
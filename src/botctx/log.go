@@ -0,0 +1,80 @@
+package botctx
+
+import (
+	"Raku/config"
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// SetupLogging replaces the default slog logger with one writing conf.Format
+// at conf.Level to stderr. Records logged with a context carry the fields
+// added by WithLogFields, such as those of the interaction being handled.
+// The standard log package, which discordgo logs to, is routed to it too.
+// Errors are also reported to the admins once Login enabled it.
+func SetupLogging(conf config.Log) {
+	var level slog.Level
+	level.UnmarshalText([]byte(conf.Level))
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if conf.Format == config.LogJSON {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(contextHandler{handler}))
+}
+
+type logFieldsKey struct{}
+
+// WithLogFields returns a context whose log records carry attrs in addition
+// to the fields of ctx.
+func WithLogFields(ctx context.Context, attrs ...slog.Attr) context.Context {
+	fields, _ := ctx.Value(logFieldsKey{}).([]slog.Attr)
+	fields = append(fields[:len(fields):len(fields)], attrs...)
+	return context.WithValue(ctx, logFieldsKey{}, fields)
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if fields, ok := ctx.Value(logFieldsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(fields...)
+	}
+	if r.Level >= slog.LevelError {
+		if responder := ResponderFrom(ctx); responder != nil {
+			responder.Fail()
+		}
+		captureReport(r)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func interactionLogFields(i *discordgo.InteractionCreate) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("interaction_id", i.ID),
+		slog.String("interaction", InteractionName(i)),
+	}
+	if i.GuildID != "" {
+		attrs = append(attrs, slog.String("guild_id", i.GuildID))
+	}
+	attrs = append(attrs, slog.String("channel_id", i.ChannelID))
+	if user := InteractionUser(i); user != nil {
+		attrs = append(attrs, slog.String("user_id", user.ID), slog.String("user", user.Username))
+	}
+	return attrs
+}
@@ -0,0 +1,104 @@
+package botctx
+
+import (
+	"Raku/metrics"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	interactionsTotal   = metrics.NewCounterVec("raku_interactions_total", "Interactions handled, by command or component route.", "name", "type")
+	interactionErrors   = metrics.NewCounterVec("raku_interaction_errors_total", "Interactions answered with an error, whose handler panicked or that logged an error.", "name", "type")
+	interactionDuration = metrics.NewHistogramVec("raku_interaction_duration_seconds", "Time spent in interaction handlers.", metrics.DefaultBuckets, "name", "type")
+	gatewayEvents       = metrics.NewCounterVec("raku_gateway_events_total", "Gateway connection events.", "event")
+)
+
+// connected is whether the gateway session is ready to receive events.
+var connected atomic.Bool
+
+// gateway is the session opened by Login, for reading its latency.
+var gateway atomic.Pointer[discordgo.Session]
+
+func init() {
+	metrics.NewGaugeFunc("raku_gateway_connected", "1 while the gateway session is ready, 0 otherwise.", func() float64 {
+		if connected.Load() {
+			return 1
+		}
+		return 0
+	})
+	metrics.NewGaugeFunc("raku_gateway_latency_seconds", "Time between the last heartbeat and its acknowledgement.", func() float64 {
+		if session := gateway.Load(); session != nil {
+			return session.HeartbeatLatency().Seconds()
+		}
+		return 0
+	})
+}
+
+var interactionTypes = map[discordgo.InteractionType]string{
+	discordgo.InteractionApplicationCommand:             "command",
+	discordgo.InteractionApplicationCommandAutocomplete: "autocomplete",
+	discordgo.InteractionMessageComponent:               "component",
+	discordgo.InteractionModalSubmit:                    "modal",
+}
+
+func observeInteraction(name string, i *discordgo.InteractionCreate, r *Responder, elapsed time.Duration) {
+	kind := interactionTypes[i.Type]
+	interactionsTotal.Inc(name, kind)
+	interactionDuration.Observe(elapsed.Seconds(), name, kind)
+	if r.Failed() {
+		interactionErrors.Inc(name, kind)
+	}
+}
+
+func gatewayConnect(session *discordgo.Session, e *discordgo.Connect) {
+	gatewayEvents.Inc("connect")
+}
+
+func gatewayDisconnect(session *discordgo.Session, e *discordgo.Disconnect) {
+	connected.Store(false)
+	gatewayEvents.Inc("disconnect")
+}
+
+func gatewayResumed(session *discordgo.Session, e *discordgo.Resumed) {
+	connected.Store(true)
+	gatewayEvents.Inc("resume")
+}
+
+// serveMetrics starts the metrics and health check server on addr. /readyz
+// fails while the gateway is disconnected so that a supervisor can restart
+// a bot stuck reconnecting.
+func serveMetrics(addr string) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !connected.Load() {
+			http.Error(w, "gateway disconnected", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{Addr: listener.Addr().String(), Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("serveMetrics", "err", err)
+		}
+	}()
+	slog.Info("serving metrics", "address", server.Addr)
+	return server, nil
+}
@@ -0,0 +1,114 @@
+package botctx
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type HandlerFunc func(ctx context.Context, session Session, i *discordgo.InteractionCreate)
+type Middleware func(next HandlerFunc) HandlerFunc
+
+var middlewares []Middleware
+
+// Use appends middlewares wrapping every command, component, autocomplete
+// and modal handler. The first middleware added is the outermost one.
+func Use(mw ...Middleware) {
+	middlewares = append(middlewares, mw...)
+}
+
+func chain(fn HandlerFunc) HandlerFunc {
+	for idx := len(middlewares) - 1; idx >= 0; idx-- {
+		fn = middlewares[idx](fn)
+	}
+	return fn
+}
+
+func InteractionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// InteractionName describes the handler an interaction is routed to, e.g.
+// "/anime seasonal" for a command or the custom ID of a button.
+func InteractionName(i *discordgo.InteractionCreate) string {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		data := i.ApplicationCommandData()
+		path := []string{data.Name}
+		options := data.Options
+		for len(options) > 0 && isSubcommand(options[0].Type) {
+			path = append(path, options[0].Name)
+			options = options[0].Options
+		}
+		name := "/" + strings.Join(path, " ")
+		if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+			name += " (autocomplete)"
+		}
+		return name
+	case discordgo.InteractionMessageComponent:
+		return i.MessageComponentData().CustomID
+	case discordgo.InteractionModalSubmit:
+		return i.ModalSubmitData().CustomID
+	}
+	return i.Type.String()
+}
+
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				slog.ErrorContext(ctx, "panic", "panic", r, "stack", string(debug.Stack()))
+				respondPanic(ctx, i)
+			}()
+			next(ctx, session, i)
+		}
+	}
+}
+
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+			slog.DebugContext(ctx, "interaction started")
+			next(ctx, session, i)
+		}
+	}
+}
+
+func Timing() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+			start := time.Now()
+			defer func() {
+				slog.InfoContext(ctx, "interaction finished", "duration", time.Since(start))
+			}()
+			next(ctx, session, i)
+		}
+	}
+}
+
+func respondPanic(ctx context.Context, i *discordgo.InteractionCreate) {
+	r := ResponderFrom(ctx)
+	r.Fail()
+
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+
+	res := errorResponse(Tr(ctx, "botctx.error.title"), Tr(ctx, "botctx.error.unexpected"))
+
+	err := r.Respond(res)
+	if err != nil {
+		slog.ErrorContext(ctx, "respondPanic", "err", err)
+	}
+}
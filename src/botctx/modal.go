@@ -0,0 +1,139 @@
+package botctx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type ModalFunc func(ctx context.Context, session Session, i *discordgo.InteractionCreate, args []string)
+
+const ModalDateLayout = "2006-01-02"
+
+var modalLUT = make(map[string]ModalFunc)
+
+// RegisterModal routes modal submissions whose custom ID starts with name to
+// fn. The submitted text inputs are decoded into a T, matching each field's
+// `modal` tag against the custom ID of a text input. Fields may be strings,
+// integers, booleans or dates in ModalDateLayout; empty inputs are left as
+// the zero value.
+func RegisterModal[T any](name string, fn func(ctx context.Context, session Session, i *discordgo.InteractionCreate, value *T, args []string)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		if field.Tag.Get("modal") != "" && !modalFieldSupported(field.Type) {
+			log.Fatalf("error: RegisterModal: field %v of %v has unsupported type %v", field.Name, t, field.Type)
+		}
+	}
+
+	modalLUT[name] = func(ctx context.Context, session Session, i *discordgo.InteractionCreate, args []string) {
+		var value T
+		err := decodeModal(i.ModalSubmitData(), &value)
+		if err != nil {
+			respondError(ctx, Tr(ctx, "botctx.input.title"), localizeInput(ctx, err))
+			return
+		}
+		fn(ctx, session, i, &value, args)
+	}
+}
+
+// OpenModal answers the interaction of ctx with a modal. It has to be the
+// first response, so it should be called before doing any slow work.
+func OpenModal(ctx context.Context, customID string, title string, inputs ...discordgo.TextInput) error {
+	components := make([]discordgo.MessageComponent, 0, len(inputs))
+	for _, input := range inputs {
+		components = append(components, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{input},
+		})
+	}
+
+	res := &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID:   customID,
+			Title:      title,
+			Components: components,
+		},
+	}
+
+	return ResponderFrom(ctx).Respond(res)
+}
+
+func modalValues(data discordgo.ModalSubmitInteractionData) map[string]string {
+	values := make(map[string]string)
+	for _, component := range data.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range row.Components {
+			input, ok := component.(*discordgo.TextInput)
+			if ok {
+				values[input.CustomID] = strings.TrimSpace(input.Value)
+			}
+		}
+	}
+	return values
+}
+
+func modalFieldSupported(t reflect.Type) bool {
+	if t == reflect.TypeOf(time.Time{}) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Int64, reflect.Bool:
+		return true
+	}
+	return false
+}
+
+func decodeModal(data discordgo.ModalSubmitInteractionData, out any) error {
+	values := modalValues(data)
+
+	value := reflect.ValueOf(out).Elem()
+	for idx := 0; idx < value.NumField(); idx++ {
+		name := value.Type().Field(idx).Tag.Get("modal")
+		str := values[name]
+		if name == "" || str == "" {
+			continue
+		}
+
+		field := value.Field(idx)
+
+		if field.Type() == reflect.TypeOf(time.Time{}) {
+			date, err := time.Parse(ModalDateLayout, str)
+			if err != nil {
+				return inputError("botctx.input.date", name, str, ModalDateLayout)
+			}
+			field.Set(reflect.ValueOf(date))
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(str)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return inputError("botctx.input.number", name, str)
+			}
+			field.SetInt(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(str)
+			if err != nil {
+				return inputError("botctx.input.boolean", name, str)
+			}
+			field.SetBool(b)
+		default:
+			return fmt.Errorf("%v: unsupported field type %v", name, field.Type())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,312 @@
+package botctx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type optionSpec struct {
+	index  int
+	option *discordgo.ApplicationCommandOption
+}
+
+var (
+	channelTypeNames = map[string]discordgo.ChannelType{
+		"text":           discordgo.ChannelTypeGuildText,
+		"voice":          discordgo.ChannelTypeGuildVoice,
+		"category":       discordgo.ChannelTypeGuildCategory,
+		"news":           discordgo.ChannelTypeGuildNews,
+		"news_thread":    discordgo.ChannelTypeGuildNewsThread,
+		"public_thread":  discordgo.ChannelTypeGuildPublicThread,
+		"private_thread": discordgo.ChannelTypeGuildPrivateThread,
+		"stage":          discordgo.ChannelTypeGuildStageVoice,
+		"forum":          discordgo.ChannelTypeGuildForum,
+	}
+
+	// optionMaxima holds the `max` tags of options. discordgo has no way to
+	// tell a max of 0 from none, so they are checked from here.
+	optionMaxima = make(map[*discordgo.ApplicationCommandOption]float64)
+
+	channelType = reflect.TypeOf(&discordgo.Channel{})
+	userType    = reflect.TypeOf(&discordgo.User{})
+	roleType    = reflect.TypeOf(&discordgo.Role{})
+)
+
+func optionType(t reflect.Type) (discordgo.ApplicationCommandOptionType, bool) {
+	switch t {
+	case channelType:
+		return discordgo.ApplicationCommandOptionChannel, true
+	case userType:
+		return discordgo.ApplicationCommandOptionUser, true
+	case roleType:
+		return discordgo.ApplicationCommandOptionRole, true
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return discordgo.ApplicationCommandOptionString, true
+	case reflect.Int, reflect.Int64:
+		return discordgo.ApplicationCommandOptionInteger, true
+	case reflect.Float64:
+		return discordgo.ApplicationCommandOptionNumber, true
+	case reflect.Bool:
+		return discordgo.ApplicationCommandOptionBoolean, true
+	}
+	return 0, false
+}
+
+func parseChoiceValue(kind discordgo.ApplicationCommandOptionType, str string) (interface{}, error) {
+	switch kind {
+	case discordgo.ApplicationCommandOptionInteger:
+		return strconv.ParseInt(str, 10, 64)
+	case discordgo.ApplicationCommandOptionNumber:
+		return strconv.ParseFloat(str, 64)
+	}
+	return str, nil
+}
+
+// optionSpecs reads the option definitions from the `option` tagged fields
+// of t. Other tags refine the option:
+//
+//	description:"..."                 shown in the discord client
+//	required:"true"
+//	min:"1" max:"25"                  value range, or length for strings
+//	choices:"name=value,..."
+//	channel:"text,public_thread"      accepted channel types
+//	default:"current year"            what leaving it out means, for /help
+func optionSpecs(t reflect.Type) []optionSpec {
+	specs := make([]optionSpec, 0, t.NumField())
+
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		name := field.Tag.Get("option")
+		if name == "" {
+			continue
+		}
+
+		kind, ok := optionType(field.Type)
+		if !ok {
+			log.Fatalf("error: option %q of %v has unsupported type %v", name, t, field.Type)
+		}
+
+		option := &discordgo.ApplicationCommandOption{
+			Type:        kind,
+			Name:        name,
+			Description: field.Tag.Get("description"),
+			Required:    field.Tag.Get("required") == "true",
+		}
+
+		for _, bound := range []string{"min", "max"} {
+			str := field.Tag.Get(bound)
+			if str == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(str, 64)
+			if err != nil {
+				log.Fatalf("error: option %q of %v has invalid %v %q", name, t, bound, str)
+			}
+			if bound == "max" {
+				optionMaxima[option] = value
+			}
+			if kind == discordgo.ApplicationCommandOptionString {
+				length := int(value)
+				if bound == "min" {
+					option.MinLength = &length
+				} else {
+					option.MaxLength = length
+				}
+			} else if bound == "min" {
+				option.MinValue = &value
+			} else {
+				option.MaxValue = value
+			}
+		}
+
+		if str := field.Tag.Get("choices"); str != "" {
+			for _, pair := range strings.Split(str, ",") {
+				choiceName, choiceValue, ok := strings.Cut(pair, "=")
+				if !ok {
+					choiceValue = choiceName
+				}
+				value, err := parseChoiceValue(kind, choiceValue)
+				if err != nil {
+					log.Fatalf("error: option %q of %v has invalid choice %q", name, t, pair)
+				}
+				option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{
+					Name:  choiceName,
+					Value: value,
+				})
+			}
+		}
+
+		if str := field.Tag.Get("default"); str != "" {
+			optionDefaults[option] = str
+		}
+
+		if str := field.Tag.Get("channel"); str != "" {
+			for _, channelName := range strings.Split(str, ",") {
+				channel, ok := channelTypeNames[channelName]
+				if !ok {
+					log.Fatalf("error: option %q of %v has unknown channel type %q", name, t, channelName)
+				}
+				option.ChannelTypes = append(option.ChannelTypes, channel)
+			}
+		}
+
+		specs = append(specs, optionSpec{index: idx, option: option})
+	}
+
+	return specs
+}
+
+// OptionsOf generates the command options declared by the fields of T.
+func OptionsOf[T any]() []*discordgo.ApplicationCommandOption {
+	specs := optionSpecs(reflect.TypeOf((*T)(nil)).Elem())
+	options := make([]*discordgo.ApplicationCommandOption, 0, len(specs))
+	for _, spec := range specs {
+		options = append(options, spec.option)
+	}
+	return options
+}
+
+// Bind decodes and validates the invocation's options into a T before
+// calling fn. Invalid input is answered with an ephemeral error and fn is
+// not called. Use the same T with OptionsOf for the command's Options.
+func Bind[T any](fn func(ctx context.Context, session Session, i *discordgo.InteractionCreate, args *T)) CommandFunc {
+	specs := optionSpecs(reflect.TypeOf((*T)(nil)).Elem())
+
+	return func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+		var args T
+		err := bindOptions(i.ApplicationCommandData(), specs, reflect.ValueOf(&args).Elem())
+		if err != nil {
+			respondError(ctx, Tr(ctx, "botctx.option.title"), localizeInput(ctx, err))
+			return
+		}
+		fn(ctx, session, i, &args)
+	}
+}
+
+func bindOptions(data discordgo.ApplicationCommandInteractionData, specs []optionSpec, out reflect.Value) error {
+	received := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, option := range CommandOptions(data) {
+		received[option.Name] = option
+	}
+
+	for _, spec := range specs {
+		option, ok := received[spec.option.Name]
+		if !ok {
+			if spec.option.Required {
+				return inputError("botctx.option.required", spec.option.Name)
+			}
+			continue
+		}
+
+		value, err := optionValue(data, spec.option, option)
+		if err != nil {
+			return err
+		}
+
+		field := out.Field(spec.index)
+		if field.Kind() == reflect.Pointer && value.Kind() != reflect.Pointer {
+			ptr := reflect.New(field.Type().Elem())
+			ptr.Elem().Set(value.Convert(field.Type().Elem()))
+			field.Set(ptr)
+		} else {
+			field.Set(value.Convert(field.Type()))
+		}
+	}
+
+	return nil
+}
+
+func hasChoice(choices []*discordgo.ApplicationCommandOptionChoice, value interface{}) bool {
+	if len(choices) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(choices, func(choice *discordgo.ApplicationCommandOptionChoice) bool {
+		return fmt.Sprint(choice.Value) == fmt.Sprint(value)
+	})
+}
+
+func optionValue(data discordgo.ApplicationCommandInteractionData, spec *discordgo.ApplicationCommandOption, option *discordgo.ApplicationCommandInteractionDataOption) (reflect.Value, error) {
+	name := spec.Name
+
+	switch spec.Type {
+	case discordgo.ApplicationCommandOptionString:
+		str := option.StringValue()
+		if spec.MinLength != nil && len([]rune(str)) < *spec.MinLength {
+			return reflect.Value{}, inputError("botctx.option.min_length", name, *spec.MinLength)
+		}
+		if max, ok := optionMaxima[spec]; ok && len([]rune(str)) > int(max) {
+			return reflect.Value{}, inputError("botctx.option.max_length", name, int(max))
+		}
+		if !hasChoice(spec.Choices, str) {
+			return reflect.Value{}, inputError("botctx.option.choice", str, name)
+		}
+		return reflect.ValueOf(str), nil
+
+	case discordgo.ApplicationCommandOptionInteger, discordgo.ApplicationCommandOptionNumber:
+		var number float64
+		var value reflect.Value
+		if spec.Type == discordgo.ApplicationCommandOptionInteger {
+			n := option.IntValue()
+			number, value = float64(n), reflect.ValueOf(n)
+		} else {
+			number = option.FloatValue()
+			value = reflect.ValueOf(number)
+		}
+		if spec.MinValue != nil && number < *spec.MinValue {
+			return reflect.Value{}, inputError("botctx.option.min", name, *spec.MinValue)
+		}
+		if max, ok := optionMaxima[spec]; ok && number > max {
+			return reflect.Value{}, inputError("botctx.option.max", name, max)
+		}
+		if !hasChoice(spec.Choices, value.Interface()) {
+			return reflect.Value{}, inputError("botctx.option.choice", value, name)
+		}
+		return value, nil
+
+	case discordgo.ApplicationCommandOptionBoolean:
+		return reflect.ValueOf(option.BoolValue()), nil
+
+	case discordgo.ApplicationCommandOptionChannel:
+		id := fmt.Sprint(option.Value)
+		channel := &discordgo.Channel{ID: id}
+		if data.Resolved != nil && data.Resolved.Channels[id] != nil {
+			channel = data.Resolved.Channels[id]
+		}
+		if len(spec.ChannelTypes) > 0 && !slices.Contains(spec.ChannelTypes, channel.Type) {
+			return reflect.Value{}, inputError("botctx.option.channel_type", id, name)
+		}
+		return reflect.ValueOf(channel), nil
+
+	case discordgo.ApplicationCommandOptionUser:
+		id := fmt.Sprint(option.Value)
+		user := &discordgo.User{ID: id}
+		if data.Resolved != nil && data.Resolved.Users[id] != nil {
+			user = data.Resolved.Users[id]
+		}
+		return reflect.ValueOf(user), nil
+
+	case discordgo.ApplicationCommandOptionRole:
+		id := fmt.Sprint(option.Value)
+		role := &discordgo.Role{ID: id}
+		if data.Resolved != nil && data.Resolved.Roles[id] != nil {
+			role = data.Resolved.Roles[id]
+		}
+		return reflect.ValueOf(role), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("`%v` has an unsupported type", name)
+}
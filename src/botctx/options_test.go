@@ -0,0 +1,157 @@
+package botctx
+
+import (
+	"Raku/discordtest"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type testArgs struct {
+	Name    string             `option:"name" description:"Name" required:"true" min:"2" max:"5"`
+	Count   int                `option:"count" min:"1" max:"10" default:"one"`
+	Ratio   *float64           `option:"ratio" max:"0"`
+	Kind    string             `option:"kind" choices:"first=a,second=b"`
+	Level   int64              `option:"level" choices:"low=1,high=2"`
+	Open    bool               `option:"open"`
+	Channel *discordgo.Channel `option:"channel" channel:"text,public_thread"`
+	skipped string
+}
+
+func TestOptionsOf(t *testing.T) {
+	options := OptionsOf[testArgs]()
+	if len(options) != 7 {
+		t.Fatalf("%v options, want 7", len(options))
+	}
+	name, count, ratio, kind, level, open, channel := options[0], options[1], options[2], options[3], options[4], options[5], options[6]
+
+	if name.Type != discordgo.ApplicationCommandOptionString || !name.Required || name.Description != "Name" {
+		t.Errorf("name is %+v", name)
+	}
+	if name.MinLength == nil || *name.MinLength != 2 || name.MaxLength != 5 {
+		t.Errorf("name has length %v to %v", name.MinLength, name.MaxLength)
+	}
+	if count.Type != discordgo.ApplicationCommandOptionInteger || count.Required || count.MinValue == nil || *count.MinValue != 1 || count.MaxValue != 10 {
+		t.Errorf("count is %+v", count)
+	}
+	if optionDefaults[count] != "one" {
+		t.Errorf("count defaults to %q", optionDefaults[count])
+	}
+	if max, ok := optionMaxima[ratio]; ratio.Type != discordgo.ApplicationCommandOptionNumber || !ok || max != 0 {
+		t.Errorf("ratio is %+v with max %v, %v", ratio, max, ok)
+	}
+	if len(kind.Choices) != 2 || kind.Choices[1].Name != "second" || kind.Choices[1].Value != "b" {
+		t.Errorf("kind has choices %+v", kind.Choices)
+	}
+	if len(level.Choices) != 2 || level.Choices[0].Value != int64(1) {
+		t.Errorf("level has choices %+v", level.Choices)
+	}
+	if open.Type != discordgo.ApplicationCommandOptionBoolean {
+		t.Errorf("open is %+v", open)
+	}
+	if !reflect.DeepEqual(channel.ChannelTypes, []discordgo.ChannelType{discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildPublicThread}) {
+		t.Errorf("channel accepts %v", channel.ChannelTypes)
+	}
+}
+
+func testOptions(values map[string]any) discordgo.ApplicationCommandInteractionData {
+	kinds := map[string]discordgo.ApplicationCommandOptionType{
+		"name":  discordgo.ApplicationCommandOptionString,
+		"count": discordgo.ApplicationCommandOptionInteger,
+		"ratio": discordgo.ApplicationCommandOptionNumber,
+		"kind":  discordgo.ApplicationCommandOptionString,
+		"level": discordgo.ApplicationCommandOptionInteger,
+		"open":  discordgo.ApplicationCommandOptionBoolean,
+	}
+
+	data := discordgo.ApplicationCommandInteractionData{Name: "test"}
+	for name, value := range values {
+		data.Options = append(data.Options, &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: kinds[name], Value: value})
+	}
+	return data
+}
+
+func TestBindOptions(t *testing.T) {
+	specs := optionSpecs(reflect.TypeOf(testArgs{}))
+	half, zero := -0.5, 0.0
+
+	tests := []struct {
+		name   string
+		values map[string]any
+		want   testArgs
+		key    string
+	}{
+		{"required only", map[string]any{"name": "frie"}, testArgs{Name: "frie"}, ""},
+		{
+			name:   "all",
+			values: map[string]any{"name": "frie", "count": 10.0, "ratio": -0.5, "kind": "b", "level": 2.0, "open": true},
+			want:   testArgs{Name: "frie", Count: 10, Ratio: &half, Kind: "b", Level: 2, Open: true},
+		},
+		{"max of zero", map[string]any{"name": "frie", "ratio": 0.0}, testArgs{Name: "frie", Ratio: &zero}, ""},
+		{"missing required", map[string]any{"count": 1.0}, testArgs{}, "botctx.option.required"},
+		{"too short", map[string]any{"name": "f"}, testArgs{}, "botctx.option.min_length"},
+		{"too long", map[string]any{"name": "frieren"}, testArgs{}, "botctx.option.max_length"},
+		{"below min", map[string]any{"name": "frie", "count": 0.0}, testArgs{}, "botctx.option.min"},
+		{"above max", map[string]any{"name": "frie", "count": 11.0}, testArgs{}, "botctx.option.max"},
+		{"above max of zero", map[string]any{"name": "frie", "ratio": 0.5}, testArgs{}, "botctx.option.max"},
+		{"not a choice", map[string]any{"name": "frie", "kind": "c"}, testArgs{}, "botctx.option.choice"},
+		{"not an integer choice", map[string]any{"name": "frie", "level": 3.0}, testArgs{}, "botctx.option.choice"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var args testArgs
+			err := bindOptions(testOptions(test.values), specs, reflect.ValueOf(&args).Elem())
+
+			var ierr *InputError
+			if test.key != "" {
+				if !errors.As(err, &ierr) || ierr.Key != test.key {
+					t.Errorf("err is %v, want %v", err, test.key)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args, test.want) {
+				t.Errorf("bound %+v, want %+v", args, test.want)
+			}
+		})
+	}
+}
+
+func TestBind(t *testing.T) {
+	srv := discordtest.NewServer()
+	defer srv.Close()
+	session := Wrap(srv.Session())
+
+	var bound *testArgs
+	handler := Bind(func(ctx context.Context, session Session, i *discordgo.InteractionCreate, args *testArgs) {
+		bound = args
+	})
+
+	i := srv.Interaction("200000000000000002", &discordgo.User{ID: "300000000000000001"}, testOptions(map[string]any{"name": "frieren"}))
+	handler(WithInteraction(context.Background(), session, i), session, i)
+
+	if bound != nil {
+		t.Errorf("called with %+v", bound)
+	}
+	callbacks := srv.Callbacks()
+	if len(callbacks) != 1 || callbacks[0].Data == nil || len(callbacks[0].Data.Embeds) != 1 {
+		t.Fatalf("callbacks %+v", callbacks)
+	}
+	embed := callbacks[0].Data.Embeds[0]
+	if embed.Title != Translate(DefaultLocale, "botctx.option.title") || embed.Description != Translate(DefaultLocale, "botctx.option.max_length", "name", 5) {
+		t.Errorf("answered %q: %q", embed.Title, embed.Description)
+	}
+
+	i = srv.Interaction("200000000000000002", &discordgo.User{ID: "300000000000000001"}, testOptions(map[string]any{"name": "frie", "count": 3.0}))
+	handler(WithInteraction(context.Background(), session, i), session, i)
+
+	if bound == nil || bound.Name != "frie" || bound.Count != 3 {
+		t.Errorf("called with %+v", bound)
+	}
+}
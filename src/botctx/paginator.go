@@ -0,0 +1,414 @@
+package botctx
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// PageInfo describes a page returned by a paginator's Fetch.
+type PageInfo struct {
+	HasNext bool
+	// Last is the number of the last page, 0 if it is not known.
+	Last int
+}
+
+// PageView is what a paginator's Render and Options get to build a page
+// from. Number starts at 1 and Selected is the value of the chosen select
+// menu option, if any. Err is the error of a failed Fetch, Items is empty
+// then.
+type PageView[S any, T any] struct {
+	State    *S
+	Number   int
+	Info     PageInfo
+	Items    T
+	Selected string
+	Err      error
+
+	route string
+	token string
+}
+
+// ActionID is the custom ID of a component added by Render, clicking it
+// calls the paginator's Action with name.
+func (v *PageView[S, T]) ActionID(name string) string {
+	return StateID(v.route, v.token, paginatorActionPrefix+name)
+}
+
+type PaginatorDesc[S any, T any] struct {
+	// Route is the path of the command the paginator belongs to. The
+	// paginator handles the components and modals of that path.
+	Route string
+	// Fetch loads page number of state.
+	Fetch func(ctx context.Context, session Session, state *S, number int) (T, PageInfo, error)
+	// Render builds the message of a page. The paginator adds its select
+	// menu above and its navigation row below the components of Render, so
+	// it may use at most three rows, or four without Options. When Fetch
+	// failed only the components of Render are kept, below an error.
+	Render func(ctx context.Context, session Session, view *PageView[S, T]) *discordgo.InteractionResponseData
+	// Options lists the items of a page in a select menu, if set.
+	Options func(view *PageView[S, T]) []discordgo.SelectMenuOption
+	// Action handles components Render added through ActionID and returns
+	// whether the paginator should go back to the first page.
+	Action func(ctx context.Context, session Session, i *discordgo.InteractionCreate, state *S, name string, values []string) bool
+	// OwnerOnly restricts the paginator to the user who started it.
+	OwnerOnly bool
+	// Timeout disables the paginator after it was not used for this long,
+	// the state TTL by default. It is cut to a minute before the token of
+	// the last interaction runs out, as the message can no longer be edited
+	// after that, so paginators last at most 14 minutes.
+	Timeout time.Duration
+}
+
+type Paginator[S any, T any] struct {
+	desc PaginatorDesc[S, T]
+}
+
+type paginatorState[S any] struct {
+	State    S
+	Page     int
+	Last     int
+	Selected string
+	Owner    string
+}
+
+type paginatorJump struct {
+	Page int `modal:"page"`
+}
+
+const paginatorActionPrefix = "do:"
+
+var paginatorTimers = struct {
+	sync.Mutex
+	timers map[string]*time.Timer
+}{timers: make(map[string]*time.Timer)}
+
+// NewPaginator creates a paginator and registers the modal it uses to jump
+// to a page under desc.Route. Its Interaction still has to be set as the
+// Interaction of the command.
+func NewPaginator[S any, T any](desc PaginatorDesc[S, T]) *Paginator[S, T] {
+	p := &Paginator[S, T]{desc: desc}
+
+	jump := BindState(p.jump)
+	modalLUT[desc.Route] = func(ctx context.Context, session Session, i *discordgo.InteractionCreate, args []string) {
+		jump(ctx, session, i, args)
+	}
+
+	return p
+}
+
+// Start answers i with the first page of state.
+func (p *Paginator[S, T]) Start(ctx context.Context, session Session, i *discordgo.InteractionCreate, state S) {
+	ps := &paginatorState[S]{State: state, Page: 1}
+	if user := InteractionUser(i); user != nil {
+		ps.Owner = user.ID
+	}
+
+	token, err := NewState(ps)
+	if err != nil {
+		slog.ErrorContext(ctx, "Paginator.Start", "err", err)
+		respondError(ctx, Tr(ctx, "botctx.error.title"), Tr(ctx, "botctx.paginator.create_failed"))
+		return
+	}
+
+	data := p.render(ctx, session, token, ps)
+
+	err = SaveState(token, ps)
+	if err != nil {
+		slog.ErrorContext(ctx, "Paginator.Start", "err", err)
+	}
+
+	p.respond(ctx, session, i, token, discordgo.InteractionResponseChannelMessageWithSource, data)
+}
+
+// Interaction handles the components of the paginator, to be set as the
+// Interaction of the command at Route.
+func (p *Paginator[S, T]) Interaction() InteractionFunc {
+	return BindState(p.interact)
+}
+
+func (p *Paginator[S, T]) allowed(ctx context.Context, i *discordgo.InteractionCreate, ps *paginatorState[S]) bool {
+	if !p.desc.OwnerOnly || ps.Owner == "" {
+		return true
+	}
+	if user := InteractionUser(i); user != nil && user.ID == ps.Owner {
+		return true
+	}
+	respondError(ctx, Tr(ctx, "botctx.paginator.not_yours_title"), Tr(ctx, "botctx.paginator.not_yours", ps.Owner))
+	return false
+}
+
+func (p *Paginator[S, T]) interact(ctx context.Context, session Session, i *discordgo.InteractionCreate, ref StateRef, ps *paginatorState[S]) {
+	if !p.allowed(ctx, i, ps) {
+		return
+	}
+
+	data := i.MessageComponentData()
+	page := ps.Page
+
+	switch ref.Action {
+	case "first":
+		page = 1
+	case "prev":
+		page--
+	case "next":
+		page++
+	case "last":
+		page = ps.Last
+	case "jump":
+		p.openJump(ctx, ref.Token, ps)
+		return
+	case "select":
+		if len(data.Values) > 0 {
+			ps.Selected = data.Values[0]
+		}
+	default:
+		name, ok := strings.CutPrefix(ref.Action, paginatorActionPrefix)
+		if ok && p.desc.Action != nil {
+			if p.desc.Action(ctx, session, i, &ps.State, name, data.Values) {
+				page = 1
+			}
+			ps.Selected = ""
+		}
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if page != ps.Page {
+		ps.Page = page
+		ps.Selected = ""
+	}
+
+	body := p.render(ctx, session, ref.Token, ps)
+	p.respond(ctx, session, i, ref.Token, discordgo.InteractionResponseUpdateMessage, body)
+}
+
+func (p *Paginator[S, T]) openJump(ctx context.Context, token string, ps *paginatorState[S]) {
+	label := Tr(ctx, "botctx.paginator.jump_label")
+	if ps.Last > 0 {
+		label = Tr(ctx, "botctx.paginator.jump_label_range", ps.Last)
+	}
+
+	err := OpenModal(ctx, StateID(p.desc.Route, token, "jump"), Tr(ctx, "botctx.paginator.jump_title"),
+		discordgo.TextInput{
+			CustomID:    "page",
+			Label:       label,
+			Style:       discordgo.TextInputShort,
+			Placeholder: strconv.Itoa(ps.Page),
+			Required:    true,
+			MaxLength:   6,
+		},
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "Paginator.openJump", "err", err)
+	}
+}
+
+func (p *Paginator[S, T]) jump(ctx context.Context, session Session, i *discordgo.InteractionCreate, ref StateRef, ps *paginatorState[S]) {
+	if !p.allowed(ctx, i, ps) {
+		return
+	}
+
+	var form paginatorJump
+	err := decodeModal(i.ModalSubmitData(), &form)
+	if err != nil {
+		respondError(ctx, Tr(ctx, "botctx.input.title"), localizeInput(ctx, err))
+		return
+	}
+
+	page := form.Page
+	if ps.Last > 0 && page > ps.Last {
+		page = ps.Last
+	}
+	if page < 1 {
+		page = 1
+	}
+	if page != ps.Page {
+		ps.Page = page
+		ps.Selected = ""
+	}
+
+	body := p.render(ctx, session, ref.Token, ps)
+	p.respond(ctx, session, i, ref.Token, discordgo.InteractionResponseUpdateMessage, body)
+}
+
+func (p *Paginator[S, T]) render(ctx context.Context, session Session, token string, ps *paginatorState[S]) *discordgo.InteractionResponseData {
+	items, info, err := p.desc.Fetch(ctx, session, &ps.State, ps.Page)
+
+	view := &PageView[S, T]{
+		State:    &ps.State,
+		Number:   ps.Page,
+		Info:     info,
+		Items:    items,
+		Selected: ps.Selected,
+		Err:      err,
+		route:    p.desc.Route,
+		token:    token,
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Paginator.render", "err", err)
+		data := &discordgo.InteractionResponseData{
+			Embeds: errorResponse(Tr(ctx, "botctx.paginator.load_failed_title"), Tr(ctx, "botctx.paginator.load_failed")).Data.Embeds,
+		}
+		if rendered := p.desc.Render(ctx, session, view); rendered != nil {
+			data.Components = rendered.Components
+		}
+		data.Components = append(data.Components, p.navigation(ctx, token, ps, PageInfo{Last: ps.Last}))
+		return data
+	}
+	ps.Last = info.Last
+
+	data := p.desc.Render(ctx, session, view)
+	if data == nil {
+		data = &discordgo.InteractionResponseData{}
+	}
+
+	components := make([]discordgo.MessageComponent, 0, 5)
+
+	if p.desc.Options != nil {
+		options := p.desc.Options(view)
+		if len(options) > 25 {
+			options = options[:25]
+		}
+		placeholder := Tr(ctx, "botctx.paginator.select")
+		for idx := range options {
+			options[idx].Default = options[idx].Value == ps.Selected
+			if options[idx].Default {
+				placeholder = options[idx].Label
+			}
+		}
+		if len(options) > 0 {
+			components = append(components, discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						MenuType:    discordgo.StringSelectMenu,
+						CustomID:    StateID(p.desc.Route, token, "select"),
+						Placeholder: placeholder,
+						Options:     options,
+					},
+				},
+			})
+		}
+	}
+
+	components = append(components, data.Components...)
+	components = append(components, p.navigation(ctx, token, ps, info))
+	data.Components = components
+
+	return data
+}
+
+func (p *Paginator[S, T]) navigation(ctx context.Context, token string, ps *paginatorState[S], info PageInfo) discordgo.MessageComponent {
+	label := Tr(ctx, "botctx.paginator.page", ps.Page)
+	if info.Last > 0 {
+		label = Tr(ctx, "botctx.paginator.page_of", ps.Page, info.Last)
+	}
+
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    Tr(ctx, "botctx.paginator.first"),
+				Style:    discordgo.SecondaryButton,
+				CustomID: StateID(p.desc.Route, token, "first"),
+				Disabled: ps.Page == 1,
+			},
+			discordgo.Button{
+				Label:    Tr(ctx, "botctx.paginator.prev"),
+				Style:    discordgo.PrimaryButton,
+				CustomID: StateID(p.desc.Route, token, "prev"),
+				Disabled: ps.Page == 1,
+			},
+			discordgo.Button{
+				Label:    label,
+				Style:    discordgo.SecondaryButton,
+				CustomID: StateID(p.desc.Route, token, "jump"),
+			},
+			discordgo.Button{
+				Label:    Tr(ctx, "botctx.paginator.next"),
+				Style:    discordgo.PrimaryButton,
+				CustomID: StateID(p.desc.Route, token, "next"),
+				Disabled: !info.HasNext,
+			},
+			discordgo.Button{
+				Label:    Tr(ctx, "botctx.paginator.last"),
+				Style:    discordgo.SecondaryButton,
+				CustomID: StateID(p.desc.Route, token, "last"),
+				Disabled: info.Last == 0 || ps.Page >= info.Last,
+			},
+		},
+	}
+}
+
+func (p *Paginator[S, T]) respond(ctx context.Context, session Session, i *discordgo.InteractionCreate, token string, kind discordgo.InteractionResponseType, data *discordgo.InteractionResponseData) {
+	res := &discordgo.InteractionResponse{
+		Type: kind,
+		Data: data,
+	}
+
+	err := ResponderFrom(ctx).Respond(res)
+	if err != nil {
+		slog.ErrorContext(ctx, "Paginator.respond", "err", err)
+		return
+	}
+
+	p.schedule(session, i, token, data.Components)
+}
+
+// schedule disables the components of the message once the paginator was
+// not used for its timeout, or when the token of i is about to run out and
+// the message can no longer be edited.
+func (p *Paginator[S, T]) schedule(session Session, i *discordgo.InteractionCreate, token string, components []discordgo.MessageComponent) {
+	timeout := p.desc.Timeout
+	if timeout <= 0 {
+		timeout = cfg.State.TTL
+	}
+	if left := time.Until(InteractionTime(i).Add(TokenTimeout)) - time.Minute; left < timeout {
+		timeout = left
+	}
+
+	paginatorTimers.Lock()
+	defer paginatorTimers.Unlock()
+
+	if timer, ok := paginatorTimers.timers[token]; ok {
+		timer.Stop()
+	}
+	paginatorTimers.timers[token] = time.AfterFunc(timeout, func() {
+		paginatorTimers.Lock()
+		delete(paginatorTimers.timers, token)
+		paginatorTimers.Unlock()
+
+		disabled := disableComponents(components)
+		_, err := session.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Components: &disabled})
+		if err != nil {
+			slog.Error("Paginator.schedule", "interaction_id", i.ID, "err", err)
+		}
+		DeleteState(token)
+	})
+}
+
+func disableComponents(components []discordgo.MessageComponent) []discordgo.MessageComponent {
+	disabled := make([]discordgo.MessageComponent, 0, len(components))
+	for _, component := range components {
+		switch component := component.(type) {
+		case discordgo.ActionsRow:
+			component.Components = disableComponents(component.Components)
+			disabled = append(disabled, component)
+		case discordgo.Button:
+			component.Disabled = component.Disabled || component.Style != discordgo.LinkButton
+			disabled = append(disabled, component)
+		case discordgo.SelectMenu:
+			component.Disabled = true
+			disabled = append(disabled, component)
+		default:
+			disabled = append(disabled, component)
+		}
+	}
+	return disabled
+}
@@ -0,0 +1,158 @@
+package botctx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type CheckFunc func(ctx context.Context, session Session, i *discordgo.InteractionCreate) error
+
+var ErrGuildOnly = errors.New("this command can only be used in a server")
+
+type PermissionError struct {
+	Subject   string
+	ChannelID string
+	Missing   int64
+}
+
+func (e *PermissionError) Error() string {
+	return e.Localize(DefaultLocale)
+}
+
+// Localize is the message of e in locale. Subjects without a translation
+// under "botctx.permissions.subjects" are used as they are.
+func (e *PermissionError) Localize(locale discordgo.Locale) string {
+	subject := e.Subject
+	if _, ok := lookup(DefaultLocale, "botctx.permissions.subjects."+subject); ok {
+		subject = Translate(locale, "botctx.permissions.subjects."+subject)
+	}
+	return Translate(locale, "botctx.permissions.missing", subject, LocalizedPermissionNames(locale, e.Missing), e.ChannelID)
+}
+
+var permissionNames = []struct {
+	bit int64
+	key string
+}{
+	{discordgo.PermissionAdministrator, "administrator"},
+	{discordgo.PermissionManageServer, "manage_server"},
+	{discordgo.PermissionManageChannels, "manage_channels"},
+	{discordgo.PermissionManageWebhooks, "manage_webhooks"},
+	{discordgo.PermissionManageMessages, "manage_messages"},
+	{discordgo.PermissionManageThreads, "manage_threads"},
+	{discordgo.PermissionViewChannel, "view_channel"},
+	{discordgo.PermissionReadMessageHistory, "read_message_history"},
+	{discordgo.PermissionSendMessages, "send_messages"},
+	{discordgo.PermissionSendMessagesInThreads, "send_messages_in_threads"},
+	{discordgo.PermissionEmbedLinks, "embed_links"},
+	{discordgo.PermissionAttachFiles, "attach_files"},
+	{discordgo.PermissionAddReactions, "add_reactions"},
+	{discordgo.PermissionUseExternalEmojis, "use_external_emojis"},
+	{discordgo.PermissionMentionEveryone, "mention_everyone"},
+}
+
+func PermissionNames(perms int64) string {
+	return LocalizedPermissionNames(DefaultLocale, perms)
+}
+
+func LocalizedPermissionNames(locale discordgo.Locale, perms int64) string {
+	names := make([]string, 0, 4)
+	for _, perm := range permissionNames {
+		if perms&perm.bit != 0 {
+			names = append(names, Translate(locale, "botctx.permissions.names."+perm.key))
+			perms &^= perm.bit
+		}
+	}
+	if perms != 0 {
+		names = append(names, fmt.Sprintf("%#x", perms))
+	}
+	return strings.Join(names, ", ")
+}
+
+func missingPermissions(have int64, want int64) int64 {
+	if have&discordgo.PermissionAdministrator != 0 {
+		return 0
+	}
+	return want &^ have
+}
+
+// RequireChannelPermissions fails with a *PermissionError when userID lacks
+// any of perms in channelID. subject names the user in the error message,
+// e.g. "You" or "I".
+func RequireChannelPermissions(session Session, subject string, userID string, channelID string, perms int64) error {
+	have, err := session.UserChannelPermissions(userID, channelID)
+	if err != nil {
+		return err
+	}
+
+	missing := missingPermissions(have, perms)
+	if missing != 0 {
+		return &PermissionError{Subject: subject, ChannelID: channelID, Missing: missing}
+	}
+	return nil
+}
+
+func checkPermissions(ctx context.Context, cmd Command, session Session, i *discordgo.InteractionCreate) error {
+	if cmd.MemberPermissions != 0 || cmd.BotPermissions != 0 {
+		if i.Member == nil {
+			return ErrGuildOnly
+		}
+
+		missing := missingPermissions(i.Member.Permissions, cmd.MemberPermissions)
+		if missing != 0 {
+			return &PermissionError{Subject: "You", ChannelID: i.ChannelID, Missing: missing}
+		}
+
+		missing = missingPermissions(i.AppPermissions, cmd.BotPermissions)
+		if missing != 0 {
+			return &PermissionError{Subject: "I", ChannelID: i.ChannelID, Missing: missing}
+		}
+	}
+
+	for _, check := range cmd.Checks {
+		err := check(ctx, session, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// localizeError is the message of a failed check in the locale of ctx, or
+// false when err is not about permissions, e.g. when they could not be
+// fetched.
+func localizeError(ctx context.Context, err error) (string, bool) {
+	var perr *PermissionError
+	if errors.As(err, &perr) {
+		return perr.Localize(Locale(ctx)), true
+	}
+	if errors.Is(err, ErrGuildOnly) {
+		return Tr(ctx, "botctx.permissions.guild_only"), true
+	}
+	return "", false
+}
+
+func withPermissions(cmd Command, next HandlerFunc) HandlerFunc {
+	if cmd.MemberPermissions == 0 && cmd.BotPermissions == 0 && len(cmd.Checks) == 0 {
+		return next
+	}
+
+	return func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+		err := checkPermissions(ctx, cmd, session, i)
+		if err != nil {
+			if msg, ok := localizeError(ctx, err); ok {
+				respondError(ctx, Tr(ctx, "botctx.permissions.title"), msg)
+				return
+			}
+			slog.ErrorContext(ctx, "withPermissions", "err", err)
+			respondError(ctx, Tr(ctx, "botctx.error.title"), Tr(ctx, "botctx.error.unexpected"))
+			return
+		}
+		next(ctx, session, i)
+	}
+}
@@ -0,0 +1,293 @@
+package botctx
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// report is an error logged while reporting is enabled, waiting to be posted
+// to the admins.
+type report struct {
+	message string
+	time    time.Time
+	err     error
+	panic   any
+	stack   string
+	source  string
+	attrs   []slog.Attr
+	// repeats and dropped count the reports left out since the last one was
+	// sent, by deduplication and by the rate limit.
+	repeats int
+	dropped int
+}
+
+type reportSeen struct {
+	at      time.Time
+	repeats int
+}
+
+var reports = struct {
+	sync.Mutex
+	seen    map[string]*reportSeen
+	sent    []time.Time
+	dropped int
+	queue   chan *report
+}{
+	seen: make(map[string]*reportSeen),
+}
+
+// ownerChannel is the DM channel with the application owner, once created.
+var ownerChannel string
+
+// startReporting makes every record logged at error level post a report to
+// the configured channel and owner, see config.ErrorReports.
+func startReporting(session *discordgo.Session) {
+	if cfg.ErrorReports.Channel == "" && !cfg.ErrorReports.DMOwner {
+		return
+	}
+
+	reports.Lock()
+	reports.queue = make(chan *report, 32)
+	queue := reports.queue
+	reports.Unlock()
+
+	go func() {
+		for rep := range queue {
+			sendReport(session, rep)
+		}
+	}()
+}
+
+// captureReport queues a report of r unless the same error was reported
+// within the dedup window or the hourly limit is used up.
+func captureReport(r slog.Record) {
+	rep := &report{message: r.Message, time: r.Time}
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "err":
+			if err, ok := a.Value.Any().(error); ok {
+				rep.err = err
+				return true
+			}
+		case "panic":
+			rep.panic = a.Value.Any()
+			return true
+		case "stack":
+			rep.stack = a.Value.String()
+			return true
+		}
+		rep.attrs = append(rep.attrs, a)
+		return true
+	})
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		rep.source = fmt.Sprintf("%v (%v:%v)", frame.Function, filepath.Base(frame.File), frame.Line)
+	}
+
+	key := rep.message + "\x00" + rep.source + "\x00" + rep.errorText()
+
+	reports.Lock()
+	defer reports.Unlock()
+
+	if reports.queue == nil {
+		return
+	}
+
+	now := time.Now()
+	for k, seen := range reports.seen {
+		expired := now.Sub(seen.at) >= cfg.ErrorReports.Dedup
+		if expired && (seen.repeats == 0 || now.Sub(seen.at) >= 24*time.Hour) {
+			delete(reports.seen, k)
+		}
+	}
+	seen, ok := reports.seen[key]
+	if ok && now.Sub(seen.at) < cfg.ErrorReports.Dedup {
+		seen.repeats++
+		return
+	}
+
+	for len(reports.sent) > 0 && now.Sub(reports.sent[0]) >= time.Hour {
+		reports.sent = reports.sent[1:]
+	}
+	if len(reports.sent) >= cfg.ErrorReports.PerHour {
+		reports.dropped++
+		return
+	}
+
+	if ok {
+		rep.repeats = seen.repeats
+	}
+	rep.dropped = reports.dropped
+
+	select {
+	case reports.queue <- rep:
+		reports.seen[key] = &reportSeen{at: now}
+		reports.sent = append(reports.sent, now)
+		reports.dropped = 0
+	default:
+		reports.dropped++
+	}
+}
+
+func (rep *report) errorText() string {
+	if rep.err != nil {
+		return rep.err.Error()
+	}
+	if rep.panic != nil {
+		return fmt.Sprint(rep.panic)
+	}
+	return ""
+}
+
+// errorChain lists err and the errors it wraps, one per line.
+func errorChain(builder *strings.Builder, err error, depth int) {
+	for ; err != nil && depth < 16; depth++ {
+		indent := strings.Repeat("  ", depth)
+		fmt.Fprintf(builder, "%v%T: %v\n", indent, err, strings.ReplaceAll(err.Error(), "\n", "\n"+indent+"  "))
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, sub := range joined.Unwrap() {
+				errorChain(builder, sub, depth+1)
+			}
+			return
+		}
+		err = errors.Unwrap(err)
+	}
+}
+
+var reportFields = []struct {
+	key  string
+	name string
+	fmt  string
+}{
+	{"interaction", "Command", "`%v`"},
+	{"guild_id", "Guild", "%v"},
+	{"channel_id", "Channel", "<#%v>"},
+	{"user_id", "User", "<@%v>"},
+	{"interaction_id", "Interaction", "%v"},
+}
+
+func reportMessage(rep *report) *discordgo.MessageSend {
+	var chain strings.Builder
+	switch {
+	case rep.err != nil:
+		errorChain(&chain, rep.err, 0)
+	case rep.panic != nil:
+		fmt.Fprintf(&chain, "panic: %v\n", rep.panic)
+	default:
+		chain.WriteString("no error attached\n")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       Truncate(rep.message, 256),
+		Description: "```\n" + Truncate(chain.String(), 4000) + "```",
+		Type:        discordgo.EmbedTypeRich,
+		Color:       cfg.Colors.Error,
+		Timestamp:   rep.time.Format(time.RFC3339),
+	}
+
+	attrs := make(map[string]string, len(rep.attrs))
+	details := make([]string, 0, len(rep.attrs))
+	for _, a := range rep.attrs {
+		attrs[a.Key] = a.Value.String()
+	}
+	for _, field := range reportFields {
+		if value, ok := attrs[field.key]; ok {
+			value = fmt.Sprintf(field.fmt, value)
+			if name, ok := attrs["user"]; ok && field.key == "user_id" {
+				value += " (" + name + ")"
+				delete(attrs, "user")
+			}
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: field.name, Value: value, Inline: true})
+			delete(attrs, field.key)
+		}
+	}
+	for _, a := range rep.attrs {
+		if value, ok := attrs[a.Key]; ok {
+			details = append(details, fmt.Sprintf("%v=%v", a.Key, value))
+		}
+	}
+	if len(details) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Details", Value: Truncate(strings.Join(details, "\n"), 1024)})
+	}
+	if rep.source != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Source", Value: Truncate("`"+rep.source+"`", 1024)})
+	}
+
+	footer := make([]string, 0, 2)
+	if rep.repeats > 0 {
+		footer = append(footer, fmt.Sprintf("Repeated %v times since the last report", rep.repeats))
+	}
+	if rep.dropped > 0 {
+		footer = append(footer, fmt.Sprintf("%v reports dropped by the rate limit", rep.dropped))
+	}
+	if len(footer) > 0 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: strings.Join(footer, " · ")}
+	}
+
+	msg := &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}}
+	if rep.stack != "" {
+		msg.Files = append(msg.Files, &discordgo.File{
+			Name:        "stack.txt",
+			ContentType: "text/plain",
+			Reader:      strings.NewReader(rep.stack),
+		})
+	}
+	return msg
+}
+
+// sendReport posts rep. Failures are logged as warnings so that they do not
+// cause reports themselves.
+func sendReport(session *discordgo.Session, rep *report) {
+	if cfg.ErrorReports.Channel != "" {
+		_, err := session.ChannelMessageSendComplex(cfg.ErrorReports.Channel, reportMessage(rep))
+		if err != nil {
+			slog.Warn("sendReport", "channel_id", cfg.ErrorReports.Channel, "err", err)
+		}
+	}
+
+	if cfg.ErrorReports.DMOwner {
+		if ownerChannel == "" {
+			channel, err := openOwnerChannel(session)
+			if err != nil {
+				slog.Warn("sendReport: owner", "err", err)
+				return
+			}
+			ownerChannel = channel
+		}
+		_, err := session.ChannelMessageSendComplex(ownerChannel, reportMessage(rep))
+		if err != nil {
+			slog.Warn("sendReport: owner", "channel_id", ownerChannel, "err", err)
+		}
+	}
+}
+
+func openOwnerChannel(session *discordgo.Session) (string, error) {
+	app, err := session.Application("@me")
+	if err != nil {
+		return "", err
+	}
+
+	owner := ""
+	if app.Team != nil {
+		owner = app.Team.OwnerID
+	} else if app.Owner != nil {
+		owner = app.Owner.ID
+	}
+	if owner == "" {
+		return "", errors.New("application has no owner")
+	}
+
+	channel, err := session.UserChannelCreate(owner)
+	if err != nil {
+		return "", err
+	}
+	return channel.ID, nil
+}
@@ -0,0 +1,243 @@
+package botctx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type responderState uint8
+
+const (
+	responsePending responderState = iota
+	responseDeferred
+	responseSent
+)
+
+type responderKey struct{}
+
+var ErrModalAfterDefer = errors.New("botctx: a modal has to be the first response to an interaction")
+
+// Responder answers a single interaction. When the handler has not replied
+// within the configured threshold it acknowledges the interaction with a
+// deferred response. Later messages are sent as followups, which keep their
+// ephemeral flag, and message updates edit the original response.
+//
+// A deferred command shows a loading message until the first followup
+// replaces it, which keeps the visibility of the loading message. Followups
+// of the other visibility delete it and are sent as messages of their own.
+type Responder struct {
+	session     Session
+	interaction *discordgo.Interaction
+
+	mutex  sync.Mutex
+	state  responderState
+	timer  *time.Timer
+	failed bool
+}
+
+func newResponder(session Session, interaction *discordgo.Interaction, deferAfter time.Duration) *Responder {
+	r := &Responder{
+		session:     session,
+		interaction: interaction,
+	}
+
+	if deferAfter > 0 && interaction.Type != discordgo.InteractionApplicationCommandAutocomplete {
+		r.timer = time.AfterFunc(deferAfter, func() {
+			r.Defer()
+		})
+	}
+
+	return r
+}
+
+func withResponder(ctx context.Context, r *Responder) context.Context {
+	return context.WithValue(ctx, responderKey{}, r)
+}
+
+// WithInteraction returns a context carrying a responder for i, for calling
+// handlers outside of HandleInteraction. It never defers on its own.
+func WithInteraction(ctx context.Context, session Session, i *discordgo.InteractionCreate) context.Context {
+	return withResponder(ctx, newResponder(session, i.Interaction, 0))
+}
+
+// ResponderFrom returns the responder of the interaction ctx was created for.
+func ResponderFrom(ctx context.Context) *Responder {
+	r, _ := ctx.Value(responderKey{}).(*Responder)
+	return r
+}
+
+func (r *Responder) stop() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+func (r *Responder) deferredType() discordgo.InteractionResponseType {
+	if r.interaction.Type == discordgo.InteractionMessageComponent {
+		return discordgo.InteractionResponseDeferredMessageUpdate
+	}
+	return discordgo.InteractionResponseDeferredChannelMessageWithSource
+}
+
+// Defer acknowledges the interaction without a message, if nothing has been
+// sent yet.
+func (r *Responder) Defer() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.state != responsePending {
+		return nil
+	}
+
+	err := r.session.InteractionRespond(r.interaction, &discordgo.InteractionResponse{Type: r.deferredType()})
+	if err != nil {
+		return err
+	}
+
+	r.state = responseDeferred
+	return nil
+}
+
+// Failed reports whether the interaction was marked as failed, see Fail.
+func (r *Responder) Failed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.failed
+}
+
+// Fail counts the interaction as failed in the metrics. botctx does so when
+// it answers with an error, the handler panics or an error is logged with
+// the context of the interaction, handlers call it for errors they show
+// without logging.
+func (r *Responder) Fail() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failed = true
+}
+
+// answered reports whether a message was sent, as opposed to only a deferred
+// response.
+func (r *Responder) answered() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.state == responseSent
+}
+
+func (r *Responder) Responded() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.state != responsePending
+}
+
+// Respond sends res as the response to the interaction. Once the interaction
+// was deferred or already answered, new messages are sent as followups and
+// other responses edit the original response.
+func (r *Responder) Respond(res *discordgo.InteractionResponse) error {
+	r.stop()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.state == responsePending {
+		err := r.session.InteractionRespond(r.interaction, res)
+		if err != nil {
+			return err
+		}
+		r.state = responseSent
+		return nil
+	}
+
+	switch res.Type {
+	case discordgo.InteractionResponseModal:
+		return ErrModalAfterDefer
+	case discordgo.InteractionResponseChannelMessageWithSource:
+		_, err := r.followup(followupParams(res.Data))
+		return err
+	}
+
+	_, err := r.session.InteractionResponseEdit(r.interaction, responseEdit(res.Data))
+	if err == nil {
+		r.state = responseSent
+	}
+	return err
+}
+
+// followup sends params as a new message, see Responder for the loading
+// message. The mutex has to be held.
+func (r *Responder) followup(params *discordgo.WebhookParams) (*discordgo.Message, error) {
+	loading := r.state == responseDeferred && r.deferredType() == discordgo.InteractionResponseDeferredChannelMessageWithSource
+	if loading && params.Flags&discordgo.MessageFlagsEphemeral != 0 {
+		err := r.session.InteractionResponseDelete(r.interaction)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg, err := r.session.FollowupMessageCreate(r.interaction, true, params)
+	if err == nil {
+		r.state = responseSent
+	}
+	return msg, err
+}
+
+// Edit changes the original response, deferring the interaction first if
+// nothing was sent yet.
+func (r *Responder) Edit(edit *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	err := r.Defer()
+	if err != nil {
+		return nil, err
+	}
+	r.stop()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	msg, err := r.session.InteractionResponseEdit(r.interaction, edit)
+	if err == nil {
+		r.state = responseSent
+	}
+	return msg, err
+}
+
+func (r *Responder) Followup(params *discordgo.WebhookParams) (*discordgo.Message, error) {
+	err := r.Defer()
+	if err != nil {
+		return nil, err
+	}
+	r.stop()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.followup(params)
+}
+
+func responseEdit(data *discordgo.InteractionResponseData) *discordgo.WebhookEdit {
+	if data == nil {
+		return &discordgo.WebhookEdit{}
+	}
+	return &discordgo.WebhookEdit{
+		Content:         &data.Content,
+		Embeds:          &data.Embeds,
+		Components:      &data.Components,
+		Files:           data.Files,
+		AllowedMentions: data.AllowedMentions,
+	}
+}
+
+func followupParams(data *discordgo.InteractionResponseData) *discordgo.WebhookParams {
+	if data == nil {
+		return &discordgo.WebhookParams{}
+	}
+	return &discordgo.WebhookParams{
+		Content:         data.Content,
+		Embeds:          data.Embeds,
+		Components:      data.Components,
+		Files:           data.Files,
+		AllowedMentions: data.AllowedMentions,
+		Flags:           data.Flags,
+	}
+}
@@ -0,0 +1,97 @@
+package botctx_test
+
+import (
+	"Raku/botctx"
+	"Raku/botctx/fake"
+	"context"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestResponderFollowupAfterDefer(t *testing.T) {
+	message := func(flags discordgo.MessageFlags) *discordgo.InteractionResponse {
+		return &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Content: "result", Flags: flags},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		kind      discordgo.InteractionType
+		defers    bool
+		responses []*discordgo.InteractionResponse
+		deletes   int
+		followups []discordgo.MessageFlags
+	}{
+		{"public", discordgo.InteractionApplicationCommand, true, []*discordgo.InteractionResponse{message(0)}, 0, []discordgo.MessageFlags{0}},
+		{"ephemeral", discordgo.InteractionApplicationCommand, true, []*discordgo.InteractionResponse{message(discordgo.MessageFlagsEphemeral)}, 1, []discordgo.MessageFlags{discordgo.MessageFlagsEphemeral}},
+		{
+			name:      "ephemeral twice",
+			kind:      discordgo.InteractionApplicationCommand,
+			defers:    true,
+			responses: []*discordgo.InteractionResponse{message(discordgo.MessageFlagsEphemeral), message(discordgo.MessageFlagsEphemeral)},
+			deletes:   1,
+			followups: []discordgo.MessageFlags{discordgo.MessageFlagsEphemeral, discordgo.MessageFlagsEphemeral},
+		},
+		{
+			name:      "ephemeral after edit",
+			kind:      discordgo.InteractionApplicationCommand,
+			defers:    true,
+			responses: []*discordgo.InteractionResponse{{Type: discordgo.InteractionResponseUpdateMessage, Data: &discordgo.InteractionResponseData{Content: "done"}}, message(discordgo.MessageFlagsEphemeral)},
+			followups: []discordgo.MessageFlags{discordgo.MessageFlagsEphemeral},
+		},
+		{"component", discordgo.InteractionMessageComponent, true, []*discordgo.InteractionResponse{message(discordgo.MessageFlagsEphemeral)}, 0, []discordgo.MessageFlags{discordgo.MessageFlagsEphemeral}},
+		{"not deferred", discordgo.InteractionApplicationCommand, false, []*discordgo.InteractionResponse{message(discordgo.MessageFlagsEphemeral)}, 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := fake.New()
+			i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{ID: "10", Type: test.kind, Token: "token"}}
+			r := botctx.ResponderFrom(botctx.WithInteraction(context.Background(), session, i))
+
+			if test.defers {
+				if err := r.Defer(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, res := range test.responses {
+				if err := r.Respond(res); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if session.ResponseDeletes != test.deletes {
+				t.Errorf("original deleted %v times, want %v", session.ResponseDeletes, test.deletes)
+			}
+			if len(session.Followups) != len(test.followups) {
+				t.Fatalf("%v followups, want %v", len(session.Followups), len(test.followups))
+			}
+			for idx, followup := range session.Followups {
+				if followup.Flags != test.followups[idx] {
+					t.Errorf("followup %v has flags %v, want %v", idx, followup.Flags, test.followups[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestResponderFollowupMethod(t *testing.T) {
+	session := fake.New()
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{ID: "10", Type: discordgo.InteractionApplicationCommand, Token: "token"}}
+	r := botctx.ResponderFrom(botctx.WithInteraction(context.Background(), session, i))
+
+	_, err := r.Followup(&discordgo.WebhookParams{Content: "secret", Flags: discordgo.MessageFlagsEphemeral})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(session.Responses) != 1 || session.Responses[0].Type != discordgo.InteractionResponseDeferredChannelMessageWithSource {
+		t.Errorf("responses %+v, want a deferred message", session.Responses)
+	}
+	if session.ResponseDeletes != 1 || len(session.Followups) != 1 {
+		t.Errorf("deleted %v times and sent %v followups", session.ResponseDeletes, len(session.Followups))
+	}
+}
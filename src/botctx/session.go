@@ -0,0 +1,48 @@
+package botctx
+
+import (
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Session is the part of the discord api handlers use. The gateway
+// connection and command registration stay on *discordgo.Session.
+type Session interface {
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error)
+
+	WebhookCreate(channelID, name, avatar string, options ...discordgo.RequestOption) (*discordgo.Webhook, error)
+	WebhookDelete(webhookID string, options ...discordgo.RequestOption) error
+	WebhookExecute(webhookID, token string, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	WebhookThreadExecute(webhookID, token string, wait bool, threadID string, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+	// HTTPClient is used for requests outside of discord, e.g. AniList or
+	// attachment downloads.
+	HTTPClient() *http.Client
+	// BotUser is the user the bot is logged in as.
+	BotUser() *discordgo.User
+}
+
+type discordSession struct {
+	*discordgo.Session
+}
+
+// Wrap adapts a discordgo session to Session.
+func Wrap(session *discordgo.Session) Session {
+	return discordSession{session}
+}
+
+func (s discordSession) HTTPClient() *http.Client {
+	return s.Client
+}
+
+func (s discordSession) BotUser() *discordgo.User {
+	return s.State.User
+}
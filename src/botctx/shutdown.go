@@ -0,0 +1,149 @@
+package botctx
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ShutdownGrace is how long handlers get to return after their contexts were
+// cancelled during shutdown.
+const ShutdownGrace = 5 * time.Second
+
+type inflightSet struct {
+	mutex   sync.Mutex
+	closing bool
+	running map[*Responder]struct{}
+	wg      sync.WaitGroup
+}
+
+var inflight = inflightSet{running: make(map[*Responder]struct{})}
+
+// track registers a handler about to run. It returns false once shutdown has
+// started and no new work should be accepted.
+func (s *inflightSet) track(r *Responder) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.running[r] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *inflightSet) untrack(r *Responder) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.running, r)
+	s.wg.Done()
+}
+
+func (s *inflightSet) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closing = true
+}
+
+func (s *inflightSet) remaining() []*Responder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	responders := make([]*Responder, 0, len(s.running))
+	for r := range s.running {
+		responders = append(responders, r)
+	}
+	return responders
+}
+
+// wait blocks until every tracked handler returned or timeout elapsed and
+// reports whether they all did.
+func (s *inflightSet) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// shutdown stops accepting interactions and waits up to timeout for running
+// handlers. Handlers still running after that are cancelled, and whatever
+// has not returned after ShutdownGrace gets its response replaced with a
+// restart notice.
+func shutdown(timeout time.Duration) {
+	inflight.close()
+
+	if inflight.wait(timeout) {
+		cancelRoot()
+		return
+	}
+
+	slog.Warn("shutdown: handlers still running, cancelling", "running", len(inflight.remaining()), "timeout", timeout)
+	cancelRoot()
+
+	if inflight.wait(ShutdownGrace) {
+		return
+	}
+
+	for _, r := range inflight.remaining() {
+		notifyRestart(r)
+	}
+}
+
+func restartResponse(locale discordgo.Locale) *discordgo.InteractionResponse {
+	return errorResponse(Translate(locale, "botctx.restart.title"), Translate(locale, "botctx.restart.description"))
+}
+
+// notifyRestart tells the user an interaction was interrupted by a restart,
+// replacing the loading message of a deferred response or an error. Answers
+// the handler got out before it was stopped are left alone.
+func notifyRestart(r *Responder) {
+	if r.interaction.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+	if r.answered() && !r.Failed() {
+		return
+	}
+
+	res := restartResponse(InteractionLocale(r.interaction))
+
+	var err error
+	if r.Responded() {
+		_, err = r.Edit(responseEdit(res.Data))
+	} else {
+		err = r.Respond(res)
+	}
+	if err != nil {
+		slog.Error("notifyRestart", "interaction_id", r.interaction.ID, "err", err)
+	}
+}
+
+// Stopping reports whether ctx was cancelled because the bot is shutting
+// down, as opposed to the interaction running out of time.
+func Stopping(ctx context.Context) bool {
+	return ctx.Err() != nil && rootCtx.Err() != nil
+}
+
+// Detach returns a context with the values of ctx that is only cancelled
+// when the bot shuts down, for work that may outlive the interaction token.
+// The interaction can only be answered while ctx is not done.
+func Detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(rootCtx, cancel)
+	return detached, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,61 @@
+package botctx
+
+import (
+	"Raku/discordtest"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestNotifyRestart(t *testing.T) {
+	message := &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: "result"},
+	}
+
+	tests := []struct {
+		name         string
+		autocomplete bool
+		before       func(r *Responder)
+		callbacks    int
+		edits        int
+		notified     bool
+	}{
+		{"pending", false, func(r *Responder) {}, 1, 0, true},
+		{"deferred", false, func(r *Responder) { r.Defer() }, 1, 1, true},
+		{"answered", false, func(r *Responder) { r.Respond(message) }, 1, 0, false},
+		{"answered after defer", false, func(r *Responder) { r.Defer(); r.Respond(message) }, 1, 0, false},
+		{"failed", false, func(r *Responder) { r.Respond(message); r.Fail() }, 1, 1, true},
+		{"autocomplete", true, func(r *Responder) {}, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := discordtest.NewServer()
+			defer srv.Close()
+
+			i := srv.Interaction(testChannelID, &discordgo.User{ID: testUserID}, discordgo.ApplicationCommandInteractionData{Name: "anime"})
+			if test.autocomplete {
+				i.Type = discordgo.InteractionApplicationCommandAutocomplete
+			}
+			r := newResponder(Wrap(srv.Session()), i.Interaction, 0)
+			test.before(r)
+			notifyRestart(r)
+
+			restart := restartResponse(DefaultLocale).Data.Embeds[0].Title
+			callbacks, edits := srv.Callbacks(), srv.Edits()
+			if len(callbacks) != test.callbacks || len(edits) != test.edits {
+				t.Fatalf("%v callbacks and %v edits, want %v and %v", len(callbacks), len(edits), test.callbacks, test.edits)
+			}
+
+			notified := len(edits) > 0 && len(edits[0].Params.Embeds) == 1 && edits[0].Params.Embeds[0].Title == restart
+			if len(edits) == 0 && len(callbacks) > 0 {
+				data := callbacks[len(callbacks)-1].Data
+				notified = data != nil && len(data.Embeds) == 1 && data.Embeds[0].Title == restart
+			}
+			if notified != test.notified {
+				t.Errorf("notified is %v, want %v", notified, test.notified)
+			}
+		})
+	}
+}
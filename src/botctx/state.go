@@ -0,0 +1,344 @@
+package botctx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	ErrStateExpired = errors.New("state expired")
+	ErrStateToken   = errors.New("malformed state token")
+)
+
+// StateStore keeps the state of components between interactions, keyed by
+// the token placed in their custom IDs.
+type StateStore interface {
+	Load(token string) ([]byte, error)
+	Save(token string, data []byte, expires time.Time) error
+	Delete(token string) error
+}
+
+// StateRef is the custom ID payload of a component with stored state. Action
+// tells apart the components sharing the state of one message.
+type StateRef struct {
+	Token  string
+	Action string
+}
+
+const stateSweepInterval = time.Minute
+
+var stateStore StateStore = newMemoryStore()
+
+func openStateStore(dir string) (StateStore, error) {
+	if dir == "" {
+		return newMemoryStore(), nil
+	}
+	return newFileStore(dir)
+}
+
+// stateTokenBytes is the size of the random part of tokens, which encode to
+// 11 characters.
+const stateTokenBytes = 8
+
+// NewState stores value for the configured TTL and returns its token.
+func NewState(value any) (string, error) {
+	buf := make([]byte, stateTokenBytes)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, SaveState(token, value)
+}
+
+// SaveState replaces the state of token and renews its TTL.
+func SaveState(token string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return stateStore.Save(token, data, time.Now().Add(cfg.State.TTL))
+}
+
+// LoadState decodes the state of token into out, or returns ErrStateExpired.
+func LoadState(token string, out any) error {
+	data, err := stateStore.Load(token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func DeleteState(token string) error {
+	return stateStore.Delete(token)
+}
+
+// StateID is the custom ID of a component routed to route which acts on the
+// state of token.
+func StateID(route string, token string, action string) string {
+	return CustomID(route, StateRef{Token: token, Action: action})
+}
+
+type stateLock struct {
+	sync.Mutex
+	users int
+}
+
+// stateLocks holds a lock for each token in use, so that components clicked
+// at the same time do not both change the state they loaded and have the
+// last save win.
+var stateLocks = struct {
+	sync.Mutex
+	tokens map[string]*stateLock
+}{tokens: make(map[string]*stateLock)}
+
+// lockState waits for the other users of token to be done and returns the
+// function that lets the next one in.
+func lockState(token string) func() {
+	stateLocks.Lock()
+	lock, ok := stateLocks.tokens[token]
+	if !ok {
+		lock = &stateLock{}
+		stateLocks.tokens[token] = lock
+	}
+	lock.users++
+	stateLocks.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		stateLocks.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(stateLocks.tokens, token)
+		}
+		stateLocks.Unlock()
+	}
+}
+
+// BindState loads the state a component was created with before calling fn
+// and saves it again afterwards, so fn may change it. Interactions on the same
+// state run one at a time. Components whose state expired get told so
+// instead.
+func BindState[S any](fn func(ctx context.Context, session Session, i *discordgo.InteractionCreate, ref StateRef, state *S)) InteractionFunc {
+	return BindComponent(func(ctx context.Context, session Session, i *discordgo.InteractionCreate, ref *StateRef) {
+		unlock := lockState(ref.Token)
+		defer unlock()
+
+		var state S
+		err := LoadState(ref.Token, &state)
+		if errors.Is(err, ErrStateExpired) {
+			respondError(ctx, Tr(ctx, "botctx.state.expired_title"), Tr(ctx, "botctx.state.expired"))
+			return
+		}
+		if err != nil {
+			respondError(ctx, Tr(ctx, "botctx.error.title"), Tr(ctx, "botctx.state.load_failed"))
+			return
+		}
+
+		fn(ctx, session, i, *ref, &state)
+
+		err = SaveState(ref.Token, &state)
+		if err != nil {
+			respondError(ctx, Tr(ctx, "botctx.error.title"), Tr(ctx, "botctx.state.save_failed"))
+		}
+	})
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+type memoryStore struct {
+	mutex   sync.Mutex
+	entries map[string]memoryEntry
+	swept   time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *memoryStore) Load(token string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[token]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, ErrStateExpired
+	}
+	return entry.data, nil
+}
+
+func (s *memoryStore) Save(token string, data []byte, expires time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.swept) > stateSweepInterval {
+		for key, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, key)
+			}
+		}
+		s.swept = now
+	}
+
+	s.entries[token] = memoryEntry{data: data, expires: expires}
+	return nil
+}
+
+func (s *memoryStore) Delete(token string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, token)
+	return nil
+}
+
+type fileEntry struct {
+	Expires time.Time       `json:"expires"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// fileStore keeps one json file per token in dir, so that components keep
+// working across restarts.
+type fileStore struct {
+	dir   string
+	mutex sync.Mutex
+	swept time.Time
+}
+
+func newFileStore(dir string) (*fileStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+// validStateToken reports whether token looks like one made by NewState.
+// Tokens come from custom IDs, which clients can forge when they are not
+// signed, so anything else must not reach the file system.
+func validStateToken(token string) bool {
+	if base64.RawURLEncoding.EncodedLen(stateTokenBytes) != len(token) {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil
+}
+
+func (s *fileStore) path(token string) (string, error) {
+	if !validStateToken(token) {
+		return "", ErrStateToken
+	}
+	return filepath.Join(s.dir, token+".json"), nil
+}
+
+func (s *fileStore) Load(token string) ([]byte, error) {
+	path, err := s.path(token)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrStateExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry fileEntry
+	err = json.Unmarshal(content, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(entry.Expires) {
+		return nil, ErrStateExpired
+	}
+	return entry.Data, nil
+}
+
+func (s *fileStore) Save(token string, data []byte, expires time.Time) error {
+	s.sweep()
+
+	path, err := s.path(token)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(fileEntry{Expires: expires, Data: data})
+	if err != nil {
+		return err
+	}
+
+	// Written next to the target and renamed so readers never see half a
+	// file, each save with its own temporary file.
+	tmp, err := os.CreateTemp(s.dir, token+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (s *fileStore) Delete(token string) error {
+	path, err := s.path(token)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *fileStore) sweep() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.swept) < stateSweepInterval {
+		return
+	}
+	s.swept = now
+
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		token, ok := strings.CutSuffix(file.Name(), ".json")
+		if !ok {
+			continue
+		}
+		_, err := s.Load(token)
+		if errors.Is(err, ErrStateExpired) {
+			os.Remove(filepath.Join(s.dir, file.Name()))
+		}
+	}
+}
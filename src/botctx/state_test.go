@@ -0,0 +1,127 @@
+package botctx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func testStores(t *testing.T) map[string]StateStore {
+	files, err := newFileStore(filepath.Join(t.TempDir(), "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]StateStore{"memory": newMemoryStore(), "file": files}
+}
+
+// withStateStore runs the rest of the test with store holding the state.
+func withStateStore(t *testing.T, store StateStore) {
+	old := stateStore
+	stateStore = store
+	t.Cleanup(func() { stateStore = old })
+}
+
+func TestStateStoreExpiry(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			err := store.Save("AAAAAAAAAAA", []byte(`1`), time.Now().Add(time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := store.Load("AAAAAAAAAAA")
+			if err != nil || string(data) != "1" {
+				t.Errorf("loaded %q, %v", data, err)
+			}
+
+			err = store.Save("AAAAAAAAAAA", []byte(`2`), time.Now().Add(-time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Load("AAAAAAAAAAA"); !errors.Is(err, ErrStateExpired) {
+				t.Errorf("loading an expired state returned %v", err)
+			}
+			if _, err := store.Load("BBBBBBBBBBB"); !errors.Is(err, ErrStateExpired) {
+				t.Errorf("loading an unknown token returned %v", err)
+			}
+
+			err = store.Delete("AAAAAAAAAAA")
+			if err != nil {
+				t.Errorf("delete returned %v", err)
+			}
+		})
+	}
+}
+
+func TestFileStoreToken(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newFileStore(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{"", "../outside", "../../AAAAA", "AAAAAAAAAA/", "AAAAAAAAAAAA", "AAAAAAAAAA="} {
+		if err := store.Save(token, []byte(`1`), time.Now().Add(time.Minute)); !errors.Is(err, ErrStateToken) {
+			t.Errorf("saving %q returned %v", token, err)
+		}
+		if _, err := store.Load(token); !errors.Is(err, ErrStateToken) {
+			t.Errorf("loading %q returned %v", token, err)
+		}
+		if err := store.Delete(token); !errors.Is(err, ErrStateToken) {
+			t.Errorf("deleting %q returned %v", token, err)
+		}
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("%v entries next to the state directory", len(files))
+	}
+}
+
+func TestBindStateConcurrent(t *testing.T) {
+	const clicks = 20
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			withStateStore(t, store)
+
+			token, err := NewState(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			handler := BindState(func(ctx context.Context, session Session, i *discordgo.InteractionCreate, ref StateRef, count *int) {
+				loaded := *count
+				time.Sleep(time.Millisecond)
+				*count = loaded + 1
+			})
+
+			var wg sync.WaitGroup
+			for n := 0; n < clicks; n++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					handler(context.Background(), nil, &discordgo.InteractionCreate{}, []string{token, "click"})
+				}()
+			}
+			wg.Wait()
+
+			var count int
+			err = LoadState(token, &count)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != clicks {
+				t.Errorf("count is %v after %v clicks", count, clicks)
+			}
+			if n := len(stateLocks.tokens); n != 0 {
+				t.Errorf("%v locks left", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package botctx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var syncMutex sync.Mutex
+
+// cleared are the scopes already checked by clearCommands.
+var cleared = make(map[string]bool)
+
+type commandShape struct {
+	Type                     discordgo.ApplicationCommandType      `json:"type"`
+	Name                     string                                `json:"name"`
+	NameLocalizations        map[discordgo.Locale]string           `json:"name_localizations,omitempty"`
+	Description              string                                `json:"description,omitempty"`
+	DescriptionLocalizations map[discordgo.Locale]string           `json:"description_localizations,omitempty"`
+	Options                  []*discordgo.ApplicationCommandOption `json:"options,omitempty"`
+	DefaultMemberPermissions *int64                                `json:"default_member_permissions,omitempty"`
+	DMPermission             *bool                                 `json:"dm_permission,omitempty"`
+	NSFW                     bool                                  `json:"nsfw,omitempty"`
+}
+
+func commandKey(cmd *discordgo.ApplicationCommand) string {
+	kind := cmd.Type
+	if kind == 0 {
+		kind = discordgo.ChatApplicationCommand
+	}
+	return fmt.Sprintf("%v:%v", kind, cmd.Name)
+}
+
+func normalizeLocalizations(m map[discordgo.Locale]string) map[discordgo.Locale]string {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+func normalizeOptions(options []*discordgo.ApplicationCommandOption) []*discordgo.ApplicationCommandOption {
+	if len(options) == 0 {
+		return nil
+	}
+
+	res := make([]*discordgo.ApplicationCommandOption, 0, len(options))
+	for _, option := range options {
+		copied := *option
+		copied.NameLocalizations = normalizeLocalizations(copied.NameLocalizations)
+		copied.DescriptionLocalizations = normalizeLocalizations(copied.DescriptionLocalizations)
+		copied.Options = normalizeOptions(copied.Options)
+		if len(copied.ChannelTypes) == 0 {
+			copied.ChannelTypes = nil
+		}
+		copied.Choices = normalizeChoices(copied.Choices)
+		res = append(res, &copied)
+	}
+	return res
+}
+
+func normalizeChoices(choices []*discordgo.ApplicationCommandOptionChoice) []*discordgo.ApplicationCommandOptionChoice {
+	if len(choices) == 0 {
+		return nil
+	}
+
+	res := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(choices))
+	for _, choice := range choices {
+		copied := *choice
+		copied.NameLocalizations = normalizeLocalizations(copied.NameLocalizations)
+		res = append(res, &copied)
+	}
+	return res
+}
+
+// Discord fills in defaults for fields that were never sent, so both sides
+// are reduced to the fields we manage before they are compared.
+func shapeOf(cmd *discordgo.ApplicationCommand, global bool) []byte {
+	shape := commandShape{
+		Type:                     cmd.Type,
+		Name:                     cmd.Name,
+		Description:              cmd.Description,
+		Options:                  normalizeOptions(cmd.Options),
+		DefaultMemberPermissions: cmd.DefaultMemberPermissions,
+	}
+
+	if shape.Type == 0 {
+		shape.Type = discordgo.ChatApplicationCommand
+	}
+	if cmd.NameLocalizations != nil {
+		shape.NameLocalizations = normalizeLocalizations(*cmd.NameLocalizations)
+	}
+	if cmd.DescriptionLocalizations != nil {
+		shape.DescriptionLocalizations = normalizeLocalizations(*cmd.DescriptionLocalizations)
+	}
+	if cmd.NSFW != nil {
+		shape.NSFW = *cmd.NSFW
+	}
+	if global {
+		dm := true
+		if cmd.DMPermission != nil {
+			dm = *cmd.DMPermission
+		}
+		shape.DMPermission = &dm
+	}
+
+	res, _ := json.Marshal(shape)
+	return res
+}
+
+func commandEqual(a, b *discordgo.ApplicationCommand, global bool) bool {
+	return bytes.Equal(shapeOf(a, global), shapeOf(b, global))
+}
+
+func syncCommands(session *discordgo.Session, guildID string) {
+	syncMutex.Lock()
+	defer syncMutex.Unlock()
+
+	scope := commandScope(guildID)
+	appID := session.State.User.ID
+
+	existing, err := session.ApplicationCommands(appID, guildID)
+	if err != nil {
+		slog.Error("syncCommands", "scope", scope, "err", err)
+		return
+	}
+
+	registered := make(map[string]*discordgo.ApplicationCommand, len(existing))
+	for _, cmd := range existing {
+		registered[commandKey(cmd)] = cmd
+	}
+
+	var created, updated, deleted, unchanged int
+
+	for _, cmd := range commands {
+		key := commandKey(cmd)
+		old, ok := registered[key]
+		delete(registered, key)
+
+		if !ok {
+			_, err = session.ApplicationCommandCreate(appID, guildID, cmd)
+			if err != nil {
+				slog.Error("syncCommands: create", "scope", scope, "command", cmd.Name, "err", err)
+				continue
+			}
+			created++
+		} else if !commandEqual(old, cmd, guildID == "") {
+			_, err = session.ApplicationCommandEdit(appID, guildID, old.ID, cmd)
+			if err != nil {
+				slog.Error("syncCommands: edit", "scope", scope, "command", cmd.Name, "err", err)
+				continue
+			}
+			updated++
+		} else {
+			unchanged++
+		}
+	}
+
+	for _, old := range registered {
+		err = session.ApplicationCommandDelete(appID, guildID, old.ID)
+		if err != nil {
+			slog.Error("syncCommands: delete", "scope", scope, "command", old.Name, "err", err)
+			continue
+		}
+		deleted++
+	}
+
+	slog.Info("synced commands", "scope", scope, "created", created, "updated", updated, "deleted", deleted, "unchanged", unchanged)
+}
+
+// clearCommands removes the commands of a scope the registration mode does
+// not use, left over from running in another mode, e.g. the global commands
+// after switching to guild registration. Each scope is checked once.
+func clearCommands(session *discordgo.Session, guildID string) {
+	syncMutex.Lock()
+	defer syncMutex.Unlock()
+
+	if cleared[guildID] {
+		return
+	}
+
+	scope := commandScope(guildID)
+	appID := session.State.User.ID
+
+	existing, err := session.ApplicationCommands(appID, guildID)
+	if err != nil {
+		slog.Error("clearCommands", "scope", scope, "err", err)
+		return
+	}
+	cleared[guildID] = true
+	if len(existing) == 0 {
+		return
+	}
+
+	_, err = session.ApplicationCommandBulkOverwrite(appID, guildID, []*discordgo.ApplicationCommand{})
+	if err != nil {
+		slog.Error("clearCommands", "scope", scope, "err", err)
+		return
+	}
+	slog.Info("cleared commands", "scope", scope, "deleted", len(existing))
+}
+
+func commandScope(guildID string) string {
+	if guildID == "" {
+		return "global"
+	}
+	return "guild " + guildID
+}
@@ -0,0 +1,163 @@
+package botctx
+
+import (
+	"Raku/config"
+	"Raku/discordtest"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const testGuildID = "200000000000000001"
+
+// withConfig runs the rest of the test with a copy of cfg changed by fn.
+func withConfig(t *testing.T, fn func(conf *config.Config)) {
+	old := cfg
+	conf := *cfg
+	fn(&conf)
+	cfg = &conf
+	t.Cleanup(func() { cfg = old })
+}
+
+func withRegistration(t *testing.T, mode string, devGuild string) {
+	withConfig(t, func(conf *config.Config) {
+		conf.Commands = config.Commands{Registration: mode, DevGuild: devGuild}
+	})
+}
+
+func TestGuildDelete(t *testing.T) {
+	tests := []struct {
+		name         string
+		registration string
+		unavailable  bool
+		kept         int
+	}{
+		{"left", config.RegisterGuild, false, 0},
+		{"outage", config.RegisterGuild, true, 1},
+		{"global", config.RegisterGlobal, false, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withRegistration(t, test.registration, "")
+
+			srv := discordtest.NewServer()
+			defer srv.Close()
+			srv.SetCommands(testGuildID, &discordgo.ApplicationCommand{Name: "anime", Description: "anime"})
+
+			guildDelete(srv.Session(), &discordgo.GuildDelete{Guild: &discordgo.Guild{ID: testGuildID, Unavailable: test.unavailable}})
+
+			if n := len(srv.Commands(testGuildID)); n != test.kept {
+				t.Errorf("%v commands left, want %v", n, test.kept)
+			}
+		})
+	}
+}
+
+func TestClearCommands(t *testing.T) {
+	withRegistration(t, config.RegisterDev, "200000000000000002")
+	cleared = make(map[string]bool)
+
+	srv := discordtest.NewServer()
+	defer srv.Close()
+	session := srv.Session()
+	srv.SetCommands("", &discordgo.ApplicationCommand{Name: "anime", Description: "anime"})
+	srv.SetCommands(testGuildID, &discordgo.ApplicationCommand{Name: "anime", Description: "anime"})
+	srv.SetCommands("200000000000000002", &discordgo.ApplicationCommand{Name: "anime", Description: "anime"})
+
+	clearCommands(session, "")
+	guildCreate(session, &discordgo.GuildCreate{Guild: &discordgo.Guild{ID: testGuildID}})
+
+	if n := len(srv.Commands("")); n != 0 {
+		t.Errorf("%v global commands left", n)
+	}
+	if n := len(srv.Commands(testGuildID)); n != 0 {
+		t.Errorf("%v commands left in a guild that is not the dev guild", n)
+	}
+
+	// Scopes are only checked once.
+	srv.SetCommands("", &discordgo.ApplicationCommand{Name: "anime", Description: "anime"})
+	clearCommands(session, "")
+	if n := len(srv.Commands("")); n != 1 {
+		t.Errorf("global commands cleared twice")
+	}
+}
+
+func TestCommandEqual(t *testing.T) {
+	command := func(edit func(cmd *discordgo.ApplicationCommand)) *discordgo.ApplicationCommand {
+		cmd := &discordgo.ApplicationCommand{
+			Type:        discordgo.ChatApplicationCommand,
+			Name:        "anime",
+			Description: "Search and browse anime",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "season",
+					Description: "Season",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "winter", Value: "WINTER", NameLocalizations: map[discordgo.Locale]string{discordgo.Japanese: "冬"}},
+						{Name: "summer", Value: "SUMMER"},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "year",
+					Description: "Released year",
+					Choices:     []*discordgo.ApplicationCommandOptionChoice{{Name: "now", Value: int64(2023)}},
+				},
+			},
+		}
+		if edit != nil {
+			edit(cmd)
+		}
+		return cmd
+	}
+	no, yes := false, true
+	perms := int64(discordgo.PermissionManageWebhooks)
+
+	tests := []struct {
+		name   string
+		remote *discordgo.ApplicationCommand
+		global bool
+		equal  bool
+	}{
+		{"same", command(nil), false, true},
+		{"default type", command(func(cmd *discordgo.ApplicationCommand) { cmd.Type = 0 }), false, true},
+		{"empty localizations", command(func(cmd *discordgo.ApplicationCommand) {
+			empty := map[discordgo.Locale]string{}
+			cmd.NameLocalizations = &empty
+			cmd.Options[0].DescriptionLocalizations = map[discordgo.Locale]string{}
+		}), false, true},
+		{"empty choice localizations", command(func(cmd *discordgo.ApplicationCommand) {
+			cmd.Options[0].Choices[1].NameLocalizations = map[discordgo.Locale]string{}
+		}), false, true},
+		{"empty channel types", command(func(cmd *discordgo.ApplicationCommand) {
+			cmd.Options[1].ChannelTypes = []discordgo.ChannelType{}
+		}), false, true},
+		{"json number choice", command(func(cmd *discordgo.ApplicationCommand) { cmd.Options[1].Choices[0].Value = float64(2023) }), false, true},
+		{"global dm default", command(func(cmd *discordgo.ApplicationCommand) { cmd.DMPermission = &yes }), true, true},
+		{"global dm off", command(func(cmd *discordgo.ApplicationCommand) { cmd.DMPermission = &no }), true, false},
+		{"guild dm off", command(func(cmd *discordgo.ApplicationCommand) { cmd.DMPermission = &no }), false, true},
+		{"description", command(func(cmd *discordgo.ApplicationCommand) { cmd.Description = "List anime" }), false, false},
+		{"choice localization", command(func(cmd *discordgo.ApplicationCommand) {
+			cmd.Options[0].Choices[0].NameLocalizations = map[discordgo.Locale]string{discordgo.Japanese: "ふゆ"}
+		}), false, false},
+		{"choice added", command(func(cmd *discordgo.ApplicationCommand) {
+			cmd.Options[0].Choices = append(cmd.Options[0].Choices, &discordgo.ApplicationCommandOptionChoice{Name: "fall", Value: "FALL"})
+		}), false, false},
+		{"required", command(func(cmd *discordgo.ApplicationCommand) { cmd.Options[1].Required = true }), false, false},
+		{"permissions", command(func(cmd *discordgo.ApplicationCommand) { cmd.DefaultMemberPermissions = &perms }), false, false},
+		{"option order", command(func(cmd *discordgo.ApplicationCommand) {
+			cmd.Options[0], cmd.Options[1] = cmd.Options[1], cmd.Options[0]
+		}), false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			local := command(nil)
+			if equal := commandEqual(test.remote, local, test.global); equal != test.equal {
+				t.Errorf("equal is %v, want %v\n%s\n%s", equal, test.equal, shapeOf(test.remote, test.global), shapeOf(local, test.global))
+			}
+		})
+	}
+}
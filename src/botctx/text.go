@@ -0,0 +1,530 @@
+package botctx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Text commands run the chat input commands from messages like
+// "!anime-search frieren" when a prefix is configured. The name is the path
+// of the command joined with dashes and the arguments fill its options in
+// order, or by name as "name:value". Quotes keep an argument with spaces
+// together and the last string option takes the rest of the message.
+//
+// The message is turned into an application command interaction and handled
+// like one, with a Session that answers through messages in the channel.
+
+// textCommand is a command as it can be used from a message.
+type textCommand struct {
+	path    []string
+	options []*discordgo.ApplicationCommandOption
+}
+
+var textLUT = make(map[string]textCommand)
+
+func buildTextCommands() {
+	for _, cmd := range commandLUT {
+		addTextCommands([]string{cmd.Command.Name}, cmd.Command.Options)
+	}
+}
+
+func addTextCommands(path []string, options []*discordgo.ApplicationCommandOption) {
+	leaf := true
+	for _, option := range options {
+		if option.Type == discordgo.ApplicationCommandOptionSubCommand || option.Type == discordgo.ApplicationCommandOptionSubCommandGroup {
+			leaf = false
+			addTextCommands(append(path[:len(path):len(path)], option.Name), option.Options)
+		}
+	}
+	if leaf {
+		textLUT[strings.Join(path, "-")] = textCommand{path: path, options: options}
+	}
+}
+
+// usage is how cmd is typed, e.g. "!anime-seasonal [year] [season]".
+func (cmd textCommand) usage() string {
+	var builder strings.Builder
+	builder.WriteString(cfg.TextCommands.Prefix + strings.Join(cmd.path, "-"))
+	for _, option := range cmd.options {
+		if option.Required {
+			fmt.Fprintf(&builder, " <%v>", option.Name)
+		} else {
+			fmt.Fprintf(&builder, " [%v]", option.Name)
+		}
+	}
+	return builder.String()
+}
+
+func messageCreate(bot *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot || m.WebhookID != "" || m.GuildID == "" {
+		return
+	}
+
+	rest, ok := strings.CutPrefix(m.Content, cfg.TextCommands.Prefix)
+	if !ok {
+		return
+	}
+	fields := strings.FieldsFunc(rest, unicode.IsSpace)
+	if len(fields) == 0 || !strings.HasPrefix(rest, fields[0]) {
+		return
+	}
+	cmd, ok := textLUT[strings.ToLower(fields[0])]
+	if !ok {
+		return
+	}
+
+	session := &messageSession{Session: Wrap(bot), bot: bot, message: m.Message}
+	i := textInteraction(bot, m)
+
+	// Discord only enforces the default member permissions of slash
+	// commands, text commands have to check them themselves.
+	if missing := textMissingPermissions(cmd, i.Member.Permissions); missing != 0 {
+		ctx := WithInteraction(context.Background(), session, i)
+		err := &PermissionError{Subject: "You", ChannelID: m.ChannelID, Missing: missing}
+		respondError(ctx, Tr(ctx, "botctx.permissions.title"), err.Localize(Locale(ctx)))
+		return
+	}
+
+	args, err := splitTextArgs(strings.TrimSpace(rest[len(fields[0]):]))
+	var options []*discordgo.ApplicationCommandInteractionDataOption
+	if err == nil {
+		options, err = parseTextArgs(bot, m.Message, cmd.options, args, i.Data.(discordgo.ApplicationCommandInteractionData).Resolved)
+	}
+	if err != nil {
+		ctx := WithInteraction(context.Background(), session, i)
+		respondError(ctx, Tr(ctx, "botctx.option.title"), Tr(ctx, "botctx.text.usage", localizeInput(ctx, err), cmd.usage()))
+		return
+	}
+
+	for idx := len(cmd.path) - 1; idx > 0; idx-- {
+		options = []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name:    cmd.path[idx],
+			Type:    discordgo.ApplicationCommandOptionSubCommand,
+			Options: options,
+		}}
+		if idx < len(cmd.path)-1 {
+			options[0].Type = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+	}
+
+	data := i.Data.(discordgo.ApplicationCommandInteractionData)
+	data.Name = cmd.path[0]
+	data.Options = options
+	i.Data = data
+
+	HandleInteraction(session, i)
+}
+
+// textMissingPermissions is what have lacks of the default member
+// permissions of the command cmd belongs to. Zero permissions leave the
+// command to administrators, as they do for slash commands.
+func textMissingPermissions(cmd textCommand, have int64) int64 {
+	perms := commandLUT[cmd.path[0]].Command.DefaultMemberPermissions
+	if perms == nil {
+		return 0
+	}
+	if *perms == 0 {
+		return missingPermissions(have, discordgo.PermissionAdministrator)
+	}
+	return missingPermissions(have, *perms)
+}
+
+// textInteraction is the interaction a text command stands for, without its
+// command data.
+func textInteraction(bot *discordgo.Session, m *discordgo.MessageCreate) *discordgo.InteractionCreate {
+	i := &discordgo.Interaction{
+		ID:        m.ID,
+		AppID:     bot.State.User.ID,
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		Version:   1,
+		Data: discordgo.ApplicationCommandInteractionData{
+			ID: m.ID,
+			Resolved: &discordgo.ApplicationCommandInteractionDataResolved{
+				Users:    make(map[string]*discordgo.User),
+				Members:  make(map[string]*discordgo.Member),
+				Roles:    make(map[string]*discordgo.Role),
+				Channels: make(map[string]*discordgo.Channel),
+			},
+		},
+	}
+
+	member := &discordgo.Member{GuildID: m.GuildID, User: m.Author}
+	if m.Member != nil {
+		copied := *m.Member
+		member = &copied
+		member.User = m.Author
+	}
+	perms, err := bot.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		slog.Error("textInteraction", "message_id", m.ID, "err", err)
+	}
+	member.Permissions = perms
+	i.Member = member
+
+	i.AppPermissions, err = bot.UserChannelPermissions(bot.State.User.ID, m.ChannelID)
+	if err != nil {
+		slog.Error("textInteraction", "message_id", m.ID, "err", err)
+	}
+
+	if guild, err := bot.State.Guild(m.GuildID); err == nil && guild.PreferredLocale != "" {
+		locale := discordgo.Locale(guild.PreferredLocale)
+		i.GuildLocale = &locale
+	}
+
+	return &discordgo.InteractionCreate{Interaction: i}
+}
+
+// splitTextArgs splits str at spaces outside of double quotes.
+func splitTextArgs(str string) ([]string, error) {
+	var args []string
+	var builder strings.Builder
+	quoted, started := false, false
+
+	for _, r := range str {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case unicode.IsSpace(r) && !quoted:
+			if started {
+				args = append(args, builder.String())
+				builder.Reset()
+				started = false
+			}
+		default:
+			builder.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, inputError("botctx.text.quote")
+	}
+	if started {
+		args = append(args, builder.String())
+	}
+	return args, nil
+}
+
+func parseTextArgs(bot *discordgo.Session, m *discordgo.Message, specs []*discordgo.ApplicationCommandOption, args []string, resolved *discordgo.ApplicationCommandInteractionDataResolved) ([]*discordgo.ApplicationCommandInteractionDataOption, error) {
+	values := make(map[string]string, len(specs))
+	positional := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, ":")
+		if spec := textOption(specs, name); ok && spec != nil {
+			values[spec.Name] = value
+			continue
+		}
+		positional = append(positional, arg)
+	}
+
+	for idx, spec := range specs {
+		if len(positional) == 0 {
+			break
+		}
+		if _, ok := values[spec.Name]; ok {
+			continue
+		}
+
+		last := true
+		for _, next := range specs[idx+1:] {
+			if _, ok := values[next.Name]; !ok {
+				last = false
+			}
+		}
+		if last && spec.Type == discordgo.ApplicationCommandOptionString {
+			values[spec.Name] = strings.Join(positional, " ")
+			positional = nil
+			break
+		}
+		values[spec.Name] = positional[0]
+		positional = positional[1:]
+	}
+	if len(positional) > 0 {
+		return nil, inputError("botctx.text.too_many")
+	}
+
+	options := make([]*discordgo.ApplicationCommandInteractionDataOption, 0, len(values))
+	for _, spec := range specs {
+		str, ok := values[spec.Name]
+		if !ok {
+			continue
+		}
+		value, err := parseTextValue(bot, m, spec, str, resolved)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, &discordgo.ApplicationCommandInteractionDataOption{
+			Name:  spec.Name,
+			Type:  spec.Type,
+			Value: value,
+		})
+	}
+	return options, nil
+}
+
+func textOption(specs []*discordgo.ApplicationCommandOption, name string) *discordgo.ApplicationCommandOption {
+	for _, spec := range specs {
+		if strings.EqualFold(spec.Name, name) {
+			return spec
+		}
+	}
+	return nil
+}
+
+// mentionID strips the markup of a mention like "<#123>" down to its id.
+func mentionID(str string, prefixes ...string) string {
+	inner, ok := strings.CutPrefix(str, "<")
+	if !ok {
+		return str
+	}
+	inner, ok = strings.CutSuffix(inner, ">")
+	if !ok {
+		return str
+	}
+	for _, prefix := range prefixes {
+		if id, ok := strings.CutPrefix(inner, prefix); ok {
+			return id
+		}
+	}
+	return str
+}
+
+// parseTextValue converts str to the value discord would send for spec,
+// numbers are float64 like in json. Mentioned channels, users and roles are
+// added to resolved.
+func parseTextValue(bot *discordgo.Session, m *discordgo.Message, spec *discordgo.ApplicationCommandOption, str string, resolved *discordgo.ApplicationCommandInteractionDataResolved) (interface{}, error) {
+	for _, choice := range spec.Choices {
+		if strings.EqualFold(choice.Name, str) {
+			return choice.Value, nil
+		}
+	}
+
+	switch spec.Type {
+	case discordgo.ApplicationCommandOptionString:
+		return str, nil
+
+	case discordgo.ApplicationCommandOptionInteger:
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return nil, inputError("botctx.text.integer", spec.Name)
+		}
+		return float64(n), nil
+
+	case discordgo.ApplicationCommandOptionNumber:
+		n, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, inputError("botctx.text.number", spec.Name)
+		}
+		return n, nil
+
+	case discordgo.ApplicationCommandOptionBoolean:
+		switch strings.ToLower(str) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0":
+			return false, nil
+		}
+		return nil, inputError("botctx.text.boolean", spec.Name)
+
+	case discordgo.ApplicationCommandOptionChannel:
+		id := mentionID(str, "#")
+		channel, err := bot.State.Channel(id)
+		if err != nil {
+			channel, err = bot.Channel(id)
+		}
+		if err != nil {
+			return nil, inputError("botctx.text.channel", str)
+		}
+		resolved.Channels[id] = channel
+		return id, nil
+
+	case discordgo.ApplicationCommandOptionUser:
+		id := mentionID(str, "@!", "@")
+		for _, user := range m.Mentions {
+			if user.ID == id {
+				resolved.Users[id] = user
+			}
+		}
+		if resolved.Users[id] == nil {
+			user, err := bot.User(id)
+			if err != nil {
+				return nil, inputError("botctx.text.user", str)
+			}
+			resolved.Users[id] = user
+		}
+		return id, nil
+
+	case discordgo.ApplicationCommandOptionRole:
+		id := mentionID(str, "@&")
+		role, err := bot.State.Role(m.GuildID, id)
+		if err != nil {
+			return nil, inputError("botctx.text.role", str)
+		}
+		resolved.Roles[id] = role
+		return id, nil
+	}
+
+	return nil, inputError("botctx.text.unsupported", spec.Name)
+}
+
+// messageSession answers an interaction made up from a message by sending
+// messages in its channel. The first response, or the first followup after
+// deferring, becomes a reply to the message which later edits change, other
+// followups are further replies. Deferring shows the typing indicator.
+type messageSession struct {
+	Session
+	bot     *discordgo.Session
+	message *discordgo.Message
+
+	mutex sync.Mutex
+	reply *discordgo.Message
+}
+
+func (s *messageSession) send(send *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	send.Reference = s.message.Reference()
+	return s.bot.ChannelMessageSendComplex(s.message.ChannelID, send, options...)
+}
+
+func (s *messageSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data := resp.Data
+	if data == nil {
+		data = &discordgo.InteractionResponseData{}
+	}
+
+	switch resp.Type {
+	case discordgo.InteractionResponseDeferredChannelMessageWithSource, discordgo.InteractionResponseDeferredMessageUpdate:
+		return s.bot.ChannelTyping(s.message.ChannelID, options...)
+
+	case discordgo.InteractionResponseModal:
+		locale := InteractionLocale(interaction)
+		res := errorResponse(Translate(locale, "botctx.text.modal_title"), Translate(locale, "botctx.text.modal"))
+		data = res.Data
+
+	case discordgo.InteractionApplicationCommandAutocompleteResult:
+		return nil
+	}
+
+	if s.reply != nil {
+		embeds, components := data.Embeds, data.Components
+		_, err := s.edit(&discordgo.WebhookEdit{Content: &data.Content, Embeds: &embeds, Components: &components, Files: data.Files}, options...)
+		return err
+	}
+
+	msg, err := s.send(&discordgo.MessageSend{
+		Content:         data.Content,
+		Embeds:          data.Embeds,
+		Components:      data.Components,
+		Files:           data.Files,
+		AllowedMentions: data.AllowedMentions,
+	}, options...)
+	if err != nil {
+		return err
+	}
+	s.reply = msg
+	return nil
+}
+
+func (s *messageSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.edit(newresp, options...)
+}
+
+func (s *messageSession) edit(newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if s.reply == nil {
+		send := &discordgo.MessageSend{Files: newresp.Files, AllowedMentions: newresp.AllowedMentions}
+		if newresp.Content != nil {
+			send.Content = *newresp.Content
+		}
+		if newresp.Embeds != nil {
+			send.Embeds = *newresp.Embeds
+		}
+		if newresp.Components != nil {
+			send.Components = *newresp.Components
+		}
+		msg, err := s.send(send, options...)
+		if err != nil {
+			return nil, err
+		}
+		s.reply = msg
+		return msg, nil
+	}
+
+	// Unlike webhook edits, message edits clear the embeds and components
+	// they leave out.
+	edit := &discordgo.MessageEdit{
+		ID:              s.reply.ID,
+		Channel:         s.reply.ChannelID,
+		Content:         newresp.Content,
+		Embeds:          s.reply.Embeds,
+		Components:      s.reply.Components,
+		Files:           newresp.Files,
+		AllowedMentions: newresp.AllowedMentions,
+	}
+	if newresp.Embeds != nil {
+		edit.Embeds = *newresp.Embeds
+	}
+	if newresp.Components != nil {
+		edit.Components = *newresp.Components
+	}
+
+	msg, err := s.bot.ChannelMessageEditComplex(edit, options...)
+	if err != nil {
+		return nil, err
+	}
+	s.reply = msg
+	return msg, nil
+}
+
+// InteractionResponseDelete removes the reply, a deferred response only
+// showed the typing indicator which needs no cleanup.
+func (s *messageSession) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.reply == nil {
+		return nil
+	}
+	err := s.bot.ChannelMessageDelete(s.reply.ChannelID, s.reply.ID, options...)
+	if err != nil {
+		return err
+	}
+	s.reply = nil
+	return nil
+}
+
+// FollowupMessageCreate sends a further reply. After a deferred response the
+// first one becomes the reply later edits change, as it replaces the loading
+// message of an interaction.
+func (s *messageSession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	msg, err := s.send(&discordgo.MessageSend{
+		Content:         data.Content,
+		Embeds:          data.Embeds,
+		Components:      data.Components,
+		Files:           data.Files,
+		AllowedMentions: data.AllowedMentions,
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	if s.reply == nil {
+		s.reply = msg
+	}
+	return msg, nil
+}
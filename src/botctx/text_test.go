@@ -0,0 +1,201 @@
+package botctx
+
+import (
+	"Raku/config"
+	"Raku/discordtest"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	testChannelID = "200000000000000002"
+	testUserID    = "300000000000000001"
+)
+
+type textSearchArgs struct {
+	Search string `option:"search" required:"true"`
+	Limit  int    `option:"limit" min:"1" max:"10"`
+}
+
+// withTextCommands registers /anime search, /anime slow and /admin and runs
+// the rest of the test with the "!" prefix.
+func withTextCommands(t *testing.T) {
+	withConfig(t, func(conf *config.Config) {
+		conf.TextCommands = config.TextCommands{Prefix: "!"}
+		conf.Interactions.DeferAfter = 10 * time.Millisecond
+	})
+
+	reply := func(ctx context.Context, content string) {
+		ResponderFrom(ctx).Respond(&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Content: content},
+		})
+	}
+	admin := int64(discordgo.PermissionManageServer)
+
+	RegisterApplicationCommand(CommandDesc{
+		Name:        "anime",
+		Description: "anime",
+		Subcommands: []CommandDesc{
+			{
+				Name:        "search",
+				Description: "search",
+				Options:     OptionsOf[textSearchArgs](),
+				Func: Bind(func(ctx context.Context, session Session, i *discordgo.InteractionCreate, args *textSearchArgs) {
+					reply(ctx, fmt.Sprintf("%v|%v", args.Search, args.Limit))
+				}),
+			},
+			{
+				Name:        "slow",
+				Description: "slow",
+				Func: func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+					time.Sleep(50 * time.Millisecond)
+					reply(ctx, "loading")
+					done := "done"
+					ResponderFrom(ctx).Edit(&discordgo.WebhookEdit{Content: &done})
+				},
+			},
+		},
+	})
+	RegisterApplicationCommand(CommandDesc{
+		Name:                     "admin",
+		Description:              "admin",
+		DefaultMemberPermissions: &admin,
+		Func: func(ctx context.Context, session Session, i *discordgo.InteractionCreate) {
+			reply(ctx, "admin")
+		},
+	})
+	buildTextCommands()
+
+	t.Cleanup(func() {
+		delete(commandLUT, "anime")
+		delete(commandLUT, "admin")
+		textLUT = make(map[string]textCommand)
+	})
+}
+
+func newTextServer() *discordtest.Server {
+	srv := discordtest.NewServer()
+	srv.AddGuild(&discordgo.Guild{
+		ID:      testGuildID,
+		OwnerID: "300000000000000099",
+		Roles: []*discordgo.Role{{
+			ID:          testGuildID,
+			Name:        "@everyone",
+			Permissions: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages,
+		}},
+	})
+	srv.AddMember(testGuildID, &discordgo.Member{User: &discordgo.User{ID: testUserID}})
+	srv.AddMember(testGuildID, &discordgo.Member{User: &discordgo.User{ID: discordtest.AppID, Bot: true}})
+	srv.AddChannel(&discordgo.Channel{ID: testChannelID, GuildID: testGuildID, Type: discordgo.ChannelTypeGuildText})
+	return srv
+}
+
+func sendText(srv *discordtest.Server, bot *discordgo.Session, content string) {
+	messageCreate(bot, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        discordtest.Snowflake(time.Now(), 0),
+		ChannelID: testChannelID,
+		GuildID:   testGuildID,
+		Content:   content,
+		Author:    &discordgo.User{ID: testUserID, Username: "kana"},
+	}})
+}
+
+func TestSplitTextArgs(t *testing.T) {
+	tests := []struct {
+		str  string
+		args []string
+		err  bool
+	}{
+		{"", nil, false},
+		{"frieren  5", []string{"frieren", "5"}, false},
+		{`"sousou no frieren" 5`, []string{"sousou no frieren", "5"}, false},
+		{`search:"sousou no" 5`, []string{"search:sousou no", "5"}, false},
+		{`""`, []string{""}, false},
+		{`"frieren`, nil, true},
+	}
+
+	for _, test := range tests {
+		args, err := splitTextArgs(test.str)
+		if (err != nil) != test.err || fmt.Sprint(args) != fmt.Sprint(test.args) || len(args) != len(test.args) {
+			t.Errorf("%q splits into %q, %v, want %q", test.str, args, err, test.args)
+		}
+	}
+}
+
+func TestTextCommand(t *testing.T) {
+	withTextCommands(t)
+
+	tests := []struct {
+		name    string
+		content string
+		// reply is the content of the answer, or the description of the
+		// error embed.
+		reply string
+	}{
+		{"positional", "!anime-search frieren 5", "frieren|5"},
+		{"quoted", `!anime-search "sousou no frieren" 5`, "sousou no frieren|5"},
+		{"by name", "!anime-search limit:3 sousou no frieren", "sousou no frieren|3"},
+		{"case", "!Anime-Search frieren", "frieren|0"},
+		{"not an integer", "!anime-search frieren five", Translate(DefaultLocale, "botctx.text.usage", Translate(DefaultLocale, "botctx.text.integer", "limit"), "!anime-search <search> [limit]")},
+		{"too many", `!anime-search "sousou no" frieren 5`, Translate(DefaultLocale, "botctx.text.usage", Translate(DefaultLocale, "botctx.text.too_many"), "!anime-search <search> [limit]")},
+		{"open quote", `!anime-search "frieren`, Translate(DefaultLocale, "botctx.text.usage", Translate(DefaultLocale, "botctx.text.quote"), "!anime-search <search> [limit]")},
+		{"out of range", "!anime-search frieren 11", Translate(DefaultLocale, "botctx.option.max", "limit", 10)},
+		{"missing permissions", "!admin", (&PermissionError{Subject: "You", ChannelID: testChannelID, Missing: discordgo.PermissionManageServer}).Error()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newTextServer()
+			defer srv.Close()
+
+			sendText(srv, srv.Session(), test.content)
+
+			sent := srv.Sent()
+			if len(sent) != 1 {
+				t.Fatalf("sent %v messages", len(sent))
+			}
+			reply := sent[0].Params.Content
+			if len(sent[0].Params.Embeds) == 1 {
+				reply = sent[0].Params.Embeds[0].Description
+			}
+			if reply != test.reply {
+				t.Errorf("replied %q, want %q", reply, test.reply)
+			}
+		})
+	}
+
+	t.Run("ignored", func(t *testing.T) {
+		srv := newTextServer()
+		defer srv.Close()
+
+		for _, content := range []string{"anime-search frieren", "! anime-search frieren", "!unknown", "!anime frieren"} {
+			sendText(srv, srv.Session(), content)
+		}
+		if sent := srv.Sent(); len(sent) != 0 {
+			t.Errorf("answered %+v", sent)
+		}
+	})
+
+	t.Run("deferred", func(t *testing.T) {
+		srv := newTextServer()
+		defer srv.Close()
+
+		sendText(srv, srv.Session(), "!anime-slow")
+
+		if n := srv.Typing(); n != 1 {
+			t.Errorf("typing %v times, want 1", n)
+		}
+		sent, edited := srv.Sent(), srv.Edited()
+		if len(sent) != 1 || sent[0].Params.Content != "loading" {
+			t.Fatalf("sent %+v", sent)
+		}
+		if len(edited) != 1 || edited[0].MessageID != sent[0].MessageID || edited[0].Params.Content != "done" {
+			t.Errorf("edited %+v", edited)
+		}
+	})
+}
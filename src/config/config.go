@@ -0,0 +1,401 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-yaml/yaml"
+)
+
+const (
+	RegisterGlobal = "global"
+	RegisterGuild  = "guild"
+	RegisterDev    = "dev"
+)
+
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+const (
+	ScopeUser    = "user"
+	ScopeChannel = "channel"
+	ScopeGuild   = "guild"
+)
+
+// Cooldown limits how often a command runs. Duration is the time between
+// two invocations and Concurrency the number of invocations allowed to run
+// at once, both counted per Scope. Zero values disable the limit.
+type Cooldown struct {
+	Duration    time.Duration `yaml:"duration"`
+	Scope       string        `yaml:"scope"`
+	Concurrency int           `yaml:"concurrency"`
+}
+
+type Log struct {
+	// Level is the lowest level written, one of debug, info, warn or error.
+	Level string `yaml:"level" env:"RAKU_LOG_LEVEL"`
+	// Format is text for logfmt style lines or json for one object per line.
+	Format string `yaml:"format" env:"RAKU_LOG_FORMAT"`
+}
+
+type Commands struct {
+	Registration string `yaml:"registration" env:"RAKU_COMMANDS_REGISTRATION"`
+	DevGuild     string `yaml:"dev_guild" env:"RAKU_COMMANDS_DEV_GUILD"`
+}
+
+type Interactions struct {
+	// DeferAfter is how long a handler may take before the interaction is
+	// acknowledged with a deferred response. Discord allows three seconds.
+	DeferAfter time.Duration `yaml:"defer_after" env:"RAKU_INTERACTIONS_DEFER_AFTER"`
+	// CustomIDSecret signs the custom IDs of components so that clients
+	// cannot forge them. Empty disables signing.
+	CustomIDSecret string `yaml:"custom_id_secret" env:"RAKU_INTERACTIONS_CUSTOM_ID_SECRET"`
+}
+
+type State struct {
+	// TTL is how long buttons and menus keep working after they were last
+	// used. Paginators stop a minute before the 15 minute interaction token
+	// runs out even when it is longer.
+	TTL time.Duration `yaml:"ttl" env:"RAKU_STATE_TTL"`
+	// Dir keeps component state on disk so it survives restarts. Empty
+	// keeps it in memory.
+	Dir string `yaml:"dir" env:"RAKU_STATE_DIR"`
+}
+
+type Shutdown struct {
+	// Timeout is how long running interactions may take to finish before
+	// they are cancelled when the bot is stopped.
+	Timeout time.Duration `yaml:"timeout" env:"RAKU_SHUTDOWN_TIMEOUT"`
+}
+
+type TextCommands struct {
+	// Prefix enables text commands such as "!anime-search frieren" for
+	// servers where slash commands are restricted. Empty disables them.
+	// Reading messages needs the message content intent.
+	Prefix string `yaml:"prefix" env:"RAKU_TEXT_COMMANDS_PREFIX"`
+}
+
+type Metrics struct {
+	// Address serves Prometheus metrics on /metrics and health checks on
+	// /healthz and /readyz, e.g. "127.0.0.1:9090". Empty disables the server.
+	Address string `yaml:"address" env:"RAKU_METRICS_ADDRESS"`
+}
+
+type ErrorReports struct {
+	// Channel receives a report of every error logged. Empty disables
+	// reporting to a channel.
+	Channel string `yaml:"channel" env:"RAKU_ERROR_REPORTS_CHANNEL"`
+	// DMOwner also sends the reports to the owner of the application.
+	DMOwner bool `yaml:"dm_owner" env:"RAKU_ERROR_REPORTS_DM_OWNER"`
+	// Dedup is how long the same error is not reported again, repeats are
+	// counted in its next report instead.
+	Dedup time.Duration `yaml:"dedup" env:"RAKU_ERROR_REPORTS_DEDUP"`
+	// PerHour is the most reports sent in an hour, the rest is dropped.
+	PerHour int `yaml:"per_hour" env:"RAKU_ERROR_REPORTS_PER_HOUR"`
+}
+
+type Colors struct {
+	Error   int `yaml:"error" env:"RAKU_COLOR_ERROR"`
+	Info    int `yaml:"info" env:"RAKU_COLOR_INFO"`
+	Success int `yaml:"success" env:"RAKU_COLOR_SUCCESS"`
+}
+
+type Anime struct {
+	SeasonalPageSize  int `yaml:"seasonal_page_size" env:"RAKU_ANIME_SEASONAL_PAGE_SIZE"`
+	SearchLimit       int `yaml:"search_limit" env:"RAKU_ANIME_SEARCH_LIMIT"`
+	AutocompleteLimit int `yaml:"autocomplete_limit" env:"RAKU_ANIME_AUTOCOMPLETE_LIMIT"`
+}
+
+type Migrate struct {
+	ProgressInterval time.Duration `yaml:"progress_interval" env:"RAKU_MIGRATE_PROGRESS_INTERVAL"`
+}
+
+type Config struct {
+	Token    string   `yaml:"token" env:"RAKU_TOKEN"`
+	Log      Log      `yaml:"log"`
+	Commands Commands `yaml:"commands"`
+	// Cooldowns override the defaults of commands by their path, e.g. "anime seasonal".
+	Cooldowns    map[string]Cooldown `yaml:"cooldowns"`
+	Interactions Interactions        `yaml:"interactions"`
+	State        State               `yaml:"state"`
+	Shutdown     Shutdown            `yaml:"shutdown"`
+	TextCommands TextCommands        `yaml:"text_commands"`
+	Metrics      Metrics             `yaml:"metrics"`
+	ErrorReports ErrorReports        `yaml:"error_reports"`
+	Colors       Colors              `yaml:"colors"`
+	Anime        Anime               `yaml:"anime"`
+	Migrate      Migrate             `yaml:"migrate"`
+}
+
+type FieldError struct {
+	Key string
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("config: %v: %v", e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+func Default() *Config {
+	return &Config{
+		Log: Log{
+			Level:  "info",
+			Format: LogText,
+		},
+		Commands: Commands{
+			Registration: RegisterGuild,
+		},
+		Interactions: Interactions{
+			DeferAfter: 2 * time.Second,
+		},
+		State: State{
+			TTL: 15 * time.Minute,
+		},
+		Shutdown: Shutdown{
+			Timeout: 30 * time.Second,
+		},
+		ErrorReports: ErrorReports{
+			Dedup:   10 * time.Minute,
+			PerHour: 30,
+		},
+		Colors: Colors{
+			Error:   0xdd1111,
+			Info:    0x11dddd,
+			Success: 0x11ff22,
+		},
+		Anime: Anime{
+			SeasonalPageSize:  16,
+			SearchLimit:       3,
+			AutocompleteLimit: 10,
+		},
+		Migrate: Migrate{
+			ProgressInterval: 700 * time.Millisecond,
+		},
+	}
+}
+
+// DefaultPath is the config file read when no path is given.
+const DefaultPath = "config.yaml"
+
+// Load reads the yaml file at path on top of the defaults and then applies
+// environment overrides. An empty path reads DefaultPath, which may be
+// missing so that a deployment can be configured through the environment
+// alone. A path that was given has to exist.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	optional := path == ""
+	if optional {
+		path = DefaultPath
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil && !(optional && errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	if err == nil {
+		err = yaml.UnmarshalStrict(content, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("config: %v: %w", path, err)
+		}
+	}
+
+	err = applyEnv(reflect.ValueOf(cfg).Elem(), "")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Token = strings.TrimSpace(cfg.Token)
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) Validate() error {
+	if cfg.Token == "" {
+		return &FieldError{Key: "token", Err: errors.New("bot token is required (set it in the config file or RAKU_TOKEN)")}
+	}
+
+	var level slog.Level
+	err := level.UnmarshalText([]byte(cfg.Log.Level))
+	if err != nil {
+		return &FieldError{Key: "log.level", Err: fmt.Errorf("%q must be one of debug, info, warn or error", cfg.Log.Level)}
+	}
+
+	if cfg.Log.Format != LogText && cfg.Log.Format != LogJSON {
+		return &FieldError{Key: "log.format", Err: fmt.Errorf("%q must be one of text or json", cfg.Log.Format)}
+	}
+
+	switch cfg.Commands.Registration {
+	case RegisterGlobal, RegisterGuild:
+	case RegisterDev:
+		if cfg.Commands.DevGuild == "" {
+			return &FieldError{Key: "commands.dev_guild", Err: errors.New("required when registration is dev")}
+		}
+	default:
+		return &FieldError{Key: "commands.registration", Err: fmt.Errorf("%q must be one of global, guild or dev", cfg.Commands.Registration)}
+	}
+
+	for name, cooldown := range cfg.Cooldowns {
+		key := fmt.Sprintf("cooldowns.%v", name)
+		err := cooldown.Validate()
+		if err != nil {
+			return &FieldError{Key: key, Err: err}
+		}
+	}
+
+	if cfg.Interactions.DeferAfter <= 0 || cfg.Interactions.DeferAfter >= 3*time.Second {
+		return &FieldError{Key: "interactions.defer_after", Err: fmt.Errorf("%v must be above 0 and below 3s", cfg.Interactions.DeferAfter)}
+	}
+
+	if cfg.State.TTL < time.Minute {
+		return &FieldError{Key: "state.ttl", Err: fmt.Errorf("%v must be at least 1m", cfg.State.TTL)}
+	}
+
+	if cfg.Shutdown.Timeout < 0 {
+		return &FieldError{Key: "shutdown.timeout", Err: fmt.Errorf("%v must not be negative", cfg.Shutdown.Timeout)}
+	}
+
+	if strings.ContainsFunc(cfg.TextCommands.Prefix, unicode.IsSpace) {
+		return &FieldError{Key: "text_commands.prefix", Err: fmt.Errorf("%q must not contain spaces", cfg.TextCommands.Prefix)}
+	}
+
+	if cfg.ErrorReports.Dedup < 0 {
+		return &FieldError{Key: "error_reports.dedup", Err: fmt.Errorf("%v must not be negative", cfg.ErrorReports.Dedup)}
+	}
+
+	if cfg.ErrorReports.PerHour < 1 {
+		return &FieldError{Key: "error_reports.per_hour", Err: fmt.Errorf("%v must be at least 1", cfg.ErrorReports.PerHour)}
+	}
+
+	colors := map[string]int{
+		"colors.error":   cfg.Colors.Error,
+		"colors.info":    cfg.Colors.Info,
+		"colors.success": cfg.Colors.Success,
+	}
+	for key, color := range colors {
+		if color < 0 || color > 0xffffff {
+			return &FieldError{Key: key, Err: fmt.Errorf("%#x is not a valid rgb color", color)}
+		}
+	}
+
+	if cfg.Anime.SeasonalPageSize < 1 || cfg.Anime.SeasonalPageSize > 25 {
+		return &FieldError{Key: "anime.seasonal_page_size", Err: fmt.Errorf("%v must be between 1 and 25", cfg.Anime.SeasonalPageSize)}
+	}
+
+	if cfg.Anime.SearchLimit < 1 || cfg.Anime.SearchLimit > 4 {
+		return &FieldError{Key: "anime.search_limit", Err: fmt.Errorf("%v must be between 1 and 4", cfg.Anime.SearchLimit)}
+	}
+
+	if cfg.Anime.AutocompleteLimit < 1 || cfg.Anime.AutocompleteLimit > 25 {
+		return &FieldError{Key: "anime.autocomplete_limit", Err: fmt.Errorf("%v must be between 1 and 25", cfg.Anime.AutocompleteLimit)}
+	}
+
+	if cfg.Migrate.ProgressInterval < 100*time.Millisecond {
+		return &FieldError{Key: "migrate.progress_interval", Err: fmt.Errorf("%v must be at least 100ms", cfg.Migrate.ProgressInterval)}
+	}
+
+	return nil
+}
+
+func (cooldown Cooldown) Validate() error {
+	switch cooldown.Scope {
+	case ScopeUser, ScopeChannel, ScopeGuild:
+	case "":
+		if cooldown.Duration != 0 || cooldown.Concurrency != 0 {
+			return errors.New("scope is required, one of user, channel or guild")
+		}
+	default:
+		return fmt.Errorf("scope %q must be one of user, channel or guild", cooldown.Scope)
+	}
+	if cooldown.Duration < 0 {
+		return fmt.Errorf("duration %v must not be negative", cooldown.Duration)
+	}
+	if cooldown.Concurrency < 0 {
+		return fmt.Errorf("concurrency %v must not be negative", cooldown.Concurrency)
+	}
+	return nil
+}
+
+func applyEnv(value reflect.Value, prefix string) error {
+	for idx := 0; idx < value.NumField(); idx++ {
+		field := value.Type().Field(idx)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			err := applyEnv(value.Field(idx), key)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		err := setValue(value.Field(idx), env)
+		if err != nil {
+			return &FieldError{Key: key, Err: fmt.Errorf("%v: %w", name, err)}
+		}
+	}
+	return nil
+}
+
+func setValue(value reflect.Value, str string) error {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(str)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(str, 0, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %v", value.Type())
+	}
+	return nil
+}
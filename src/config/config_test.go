@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, "token: \" abc \"\nstate:\n  ttl: 5m\nanime:\n  search_limit: 2\n")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "abc" {
+		t.Errorf("token = %q, want abc", cfg.Token)
+	}
+	if cfg.State.TTL != 5*time.Minute {
+		t.Errorf("state.ttl = %v, want 5m", cfg.State.TTL)
+	}
+	if cfg.Anime.SearchLimit != 2 {
+		t.Errorf("anime.search_limit = %v, want 2", cfg.Anime.SearchLimit)
+	}
+	if cfg.Shutdown.Timeout != Default().Shutdown.Timeout {
+		t.Errorf("shutdown.timeout = %v, want the default", cfg.Shutdown.Timeout)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("err = %v, want os.ErrNotExist", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	t.Setenv("RAKU_TOKEN", "abc")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("missing default path: %v", err)
+	}
+	if cfg.Token != "abc" {
+		t.Errorf("token = %q, want abc", cfg.Token)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	path := writeConfig(t, "token: abc\nstate:\n  tll: 5m\n")
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "tll") {
+		t.Fatalf("err = %v, want the unknown key", err)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	path := writeConfig(t, "token: abc\nstate:\n  ttl: 5m\n")
+	t.Setenv("RAKU_TOKEN", " env ")
+	t.Setenv("RAKU_STATE_TTL", "10m")
+	t.Setenv("RAKU_ANIME_SEARCH_LIMIT", "4")
+	t.Setenv("RAKU_COLOR_ERROR", "0xff0000")
+	t.Setenv("RAKU_ERROR_REPORTS_DM_OWNER", "true")
+	t.Setenv("RAKU_LOG_FORMAT", LogJSON)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "env" {
+		t.Errorf("token = %q, want env", cfg.Token)
+	}
+	if cfg.State.TTL != 10*time.Minute {
+		t.Errorf("state.ttl = %v, want 10m", cfg.State.TTL)
+	}
+	if cfg.Anime.SearchLimit != 4 {
+		t.Errorf("anime.search_limit = %v, want 4", cfg.Anime.SearchLimit)
+	}
+	if cfg.Colors.Error != 0xff0000 {
+		t.Errorf("colors.error = %#x, want 0xff0000", cfg.Colors.Error)
+	}
+	if !cfg.ErrorReports.DMOwner {
+		t.Error("error_reports.dm_owner = false, want true")
+	}
+	if cfg.Log.Format != LogJSON {
+		t.Errorf("log.format = %q, want json", cfg.Log.Format)
+	}
+}
+
+func TestLoadFieldError(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		key  string
+	}{
+		{"duration", "token: abc\n", map[string]string{"RAKU_STATE_TTL": "soon"}, "state.ttl"},
+		{"int", "token: abc\n", map[string]string{"RAKU_ANIME_SEARCH_LIMIT": "three"}, "anime.search_limit"},
+		{"bool", "token: abc\n", map[string]string{"RAKU_ERROR_REPORTS_DM_OWNER": "maybe"}, "error_reports.dm_owner"},
+		{"token", "token: \"  \"\n", nil, "token"},
+		{"defer after", "token: abc\ninteractions:\n  defer_after: 3s\n", nil, "interactions.defer_after"},
+		{"log level", "token: abc\nlog:\n  level: loud\n", nil, "log.level"},
+		{"search limit", "token: abc\nanime:\n  search_limit: 5\n", nil, "anime.search_limit"},
+		{"cooldown", "token: abc\ncooldowns:\n  anime seasonal:\n    scope: world\n", nil, "cooldowns.anime seasonal"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := Load(writeConfig(t, test.yaml))
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("err = %v, want a *FieldError", err)
+			}
+			if fieldErr.Key != test.key {
+				t.Errorf("key = %q, want %q", fieldErr.Key, test.key)
+			}
+			for name := range test.env {
+				if !strings.Contains(err.Error(), name) {
+					t.Errorf("err = %v, want it to name %v", err, name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,786 @@
+// Package discordtest runs a local stand-in for the parts of the discord
+// REST api Raku uses, so that whole flows such as migrate can run offline
+// against a real *discordgo.Session.
+package discordtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// AppID is the application the sessions of the server are logged in as.
+const AppID = "100000000000000001"
+
+// File is an uploaded attachment.
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Post is a message sent to the server, either as plain json or as
+// multipart with a payload_json part. Components are kept as json since
+// discordgo cannot decode them outside of a message.
+type Post struct {
+	Token      string
+	Params     discordgo.WebhookParams
+	Components []json.RawMessage
+	Files      []File
+}
+
+// Callback is the initial response to an interaction.
+type Callback struct {
+	Token      string
+	Type       discordgo.InteractionResponseType
+	Data       *discordgo.InteractionResponseData
+	Components []json.RawMessage
+	Files      []File
+}
+
+// Message is a message the bot sent to or edited in a channel, as text
+// commands answer.
+type Message struct {
+	ChannelID string
+	MessageID string
+	Post
+}
+
+// Execution is a message posted through a webhook.
+type Execution struct {
+	WebhookID string
+	ThreadID  string
+	Post
+}
+
+// Server keeps channels, messages and webhooks in memory and records every
+// interaction response and webhook message. All fields are guarded by the
+// server, use the methods to read them while a flow is running.
+type Server struct {
+	*httptest.Server
+
+	mutex sync.Mutex
+
+	guilds      map[string]*discordgo.Guild
+	members     map[string]map[string]*discordgo.Member
+	channels    map[string]*discordgo.Channel
+	messages    map[string][]*discordgo.Message
+	attachments map[string][]byte
+	webhooks    map[string]*discordgo.Webhook
+	// commands by guild id, "" for the global ones.
+	commands map[string][]*discordgo.ApplicationCommand
+
+	acked      map[string]bool
+	callbacks  []Callback
+	edits      []Post
+	followups  []Post
+	executions []Execution
+	deleted    []string
+	sent       []Message
+	edited     []Message
+	typing     int
+
+	nextID int64
+}
+
+func NewServer() *Server {
+	s := &Server{
+		guilds:      make(map[string]*discordgo.Guild),
+		members:     make(map[string]map[string]*discordgo.Member),
+		channels:    make(map[string]*discordgo.Channel),
+		messages:    make(map[string][]*discordgo.Message),
+		attachments: make(map[string][]byte),
+		webhooks:    make(map[string]*discordgo.Webhook),
+		commands:    make(map[string][]*discordgo.ApplicationCommand),
+		acked:       make(map[string]bool),
+		nextID:      1 << 40,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Session returns a bot session whose requests to discord and its cdn go to
+// the server instead.
+func (s *Server) Session() *discordgo.Session {
+	session, _ := discordgo.New("Bot test")
+	session.Client = s.Client()
+	session.State.User = &discordgo.User{ID: AppID, Username: "Raku", Bot: true}
+	return session
+}
+
+// Client returns an http client which redirects discord hosts to the server.
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{
+		Timeout:   20 * time.Second,
+		Transport: &rewriteTransport{target: target, base: http.DefaultTransport},
+	}
+}
+
+type rewriteTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.URL.Host {
+	case "discord.com", "cdn.discordapp.com", "media.discordapp.net":
+		req = req.Clone(req.Context())
+		req.URL.Scheme = t.target.Scheme
+		req.URL.Host = t.target.Host
+		req.Host = t.target.Host
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Snowflake builds an id for something created at t. seq tells apart ids
+// from the same millisecond.
+func Snowflake(t time.Time, seq int) string {
+	ms := t.UnixMilli() - 1420070400000
+	return strconv.FormatInt(ms<<22|int64(seq&0xfff), 10)
+}
+
+func (s *Server) id() string {
+	s.nextID++
+	return strconv.FormatInt(s.nextID, 10)
+}
+
+// Interaction builds an application command interaction in channelID which
+// the session from Session can respond to.
+func (s *Server) Interaction(channelID string, user *discordgo.User, data discordgo.ApplicationCommandInteractionData) *discordgo.InteractionCreate {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	guildID := ""
+	if channel, ok := s.channels[channelID]; ok {
+		guildID = channel.GuildID
+	}
+	return &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			ID:             Snowflake(time.Now(), 0),
+			AppID:          AppID,
+			Type:           discordgo.InteractionApplicationCommand,
+			Data:           data,
+			GuildID:        guildID,
+			ChannelID:      channelID,
+			Member:         &discordgo.Member{User: user, Permissions: discordgo.PermissionAll},
+			Token:          "interaction-token-" + s.id(),
+			Version:        1,
+			AppPermissions: discordgo.PermissionAll,
+		},
+	}
+}
+
+// AddGuild stores guild. Its roles, with @everyone under the id of the
+// guild, decide the permissions of its members outside of interactions.
+func (s *Server) AddGuild(guild *discordgo.Guild) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.guilds[guild.ID] = guild
+}
+
+// AddMember adds member to guildID.
+func (s *Server) AddMember(guildID string, member *discordgo.Member) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.members[guildID] == nil {
+		s.members[guildID] = make(map[string]*discordgo.Member)
+	}
+	member.GuildID = guildID
+	s.members[guildID][member.User.ID] = member
+}
+
+func (s *Server) AddChannel(channel *discordgo.Channel) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.channels[channel.ID] = channel
+}
+
+func (s *Server) Channel(channelID string) *discordgo.Channel {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.channels[channelID]
+}
+
+// AddMessages stores msgs in channelID. They may be given in any order and
+// missing ids are derived from their timestamps.
+func (s *Server) AddMessages(channelID string, msgs ...*discordgo.Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for idx, msg := range msgs {
+		msg.ChannelID = channelID
+		if msg.ID == "" {
+			msg.ID = Snowflake(msg.Timestamp, len(s.messages[channelID])+idx)
+		}
+	}
+
+	all := append(s.messages[channelID], msgs...)
+	// Newest first, like discord returns them.
+	sort.Slice(all, func(a, b int) bool {
+		return snowflakeLess(all[b].ID, all[a].ID)
+	})
+	s.messages[channelID] = all
+}
+
+// Generate adds n messages from author to channelID, one every interval
+// starting at start, and returns them oldest first.
+func (s *Server) Generate(channelID string, author *discordgo.User, n int, start time.Time, interval time.Duration) []*discordgo.Message {
+	msgs := make([]*discordgo.Message, 0, n)
+	for idx := 0; idx < n; idx++ {
+		ts := start.Add(time.Duration(idx) * interval)
+		msgs = append(msgs, &discordgo.Message{
+			ID:        Snowflake(ts, idx),
+			Content:   fmt.Sprintf("message %v", idx),
+			Author:    author,
+			Timestamp: ts,
+			Type:      discordgo.MessageTypeDefault,
+		})
+	}
+	s.AddMessages(channelID, msgs...)
+	return msgs
+}
+
+// AddAttachment serves data as an attachment of msg through the cdn.
+func (s *Server) AddAttachment(msg *discordgo.Message, name string, contentType string, data []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	id := s.id()
+	path := "/attachments/" + msg.ChannelID + "/" + id + "/" + name
+	s.attachments[path] = data
+	msg.Attachments = append(msg.Attachments, &discordgo.MessageAttachment{
+		ID:          id,
+		URL:         discordgo.EndpointCDN + strings.TrimPrefix(path, "/"),
+		Filename:    name,
+		ContentType: contentType,
+		Size:        len(data),
+	})
+}
+
+// SetCommands registers cmds in guildID, or globally when it is empty.
+func (s *Server) SetCommands(guildID string, cmds ...*discordgo.ApplicationCommand) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, cmd := range cmds {
+		if cmd.ID == "" {
+			cmd.ID = s.id()
+		}
+		cmd.ApplicationID = AppID
+		cmd.GuildID = guildID
+	}
+	s.commands[guildID] = cmds
+}
+
+// Commands are the commands registered in guildID, or globally when it is
+// empty.
+func (s *Server) Commands(guildID string) []*discordgo.ApplicationCommand {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*discordgo.ApplicationCommand(nil), s.commands[guildID]...)
+}
+
+// Callbacks are the initial responses to interactions.
+func (s *Server) Callbacks() []Callback {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Callback(nil), s.callbacks...)
+}
+
+// Edits are the edits of original interaction responses.
+func (s *Server) Edits() []Post {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Post(nil), s.edits...)
+}
+
+func (s *Server) Followups() []Post {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Post(nil), s.followups...)
+}
+
+func (s *Server) Executions() []Execution {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Execution(nil), s.executions...)
+}
+
+// Webhooks returns the webhooks which were created and not deleted.
+func (s *Server) Webhooks() []*discordgo.Webhook {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	webhooks := make([]*discordgo.Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks
+}
+
+// Sent are the messages the bot sent to channels.
+func (s *Server) Sent() []Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Message(nil), s.sent...)
+}
+
+// Edited are the edits of messages the bot sent.
+func (s *Server) Edited() []Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Message(nil), s.edited...)
+}
+
+// Typing is how often the bot triggered the typing indicator.
+func (s *Server) Typing() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.typing
+}
+
+func (s *Server) Deleted() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.deleted...)
+}
+
+func snowflakeLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if data, ok := s.attachments[r.URL.Path]; ok && r.Method == http.MethodGet {
+		w.Write(data)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v"+discordgo.APIVersion+"/")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(parts) >= 3 && parts[0] == "applications" && parts[len(parts)-1] == "commands":
+		s.commandsRoute(w, r, parts)
+	case len(parts) == 2 && parts[0] == "guilds" && r.Method == http.MethodGet:
+		s.getGuild(w, parts[1])
+	case len(parts) == 3 && parts[0] == "guilds" && parts[2] == "roles" && r.Method == http.MethodGet:
+		s.getRoles(w, parts[1])
+	case len(parts) == 4 && parts[0] == "guilds" && parts[2] == "members" && r.Method == http.MethodGet:
+		s.getMember(w, parts[1], parts[3])
+	case len(parts) == 2 && parts[0] == "channels" && r.Method == http.MethodGet:
+		s.getChannel(w, parts[1])
+	case len(parts) == 3 && parts[0] == "channels" && parts[2] == "messages" && r.Method == http.MethodGet:
+		s.getMessages(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "channels" && parts[2] == "messages" && r.Method == http.MethodPost:
+		s.sendMessage(w, r, parts[1])
+	case len(parts) == 4 && parts[0] == "channels" && parts[2] == "messages" && r.Method == http.MethodPatch:
+		s.editMessage(w, r, parts[1], parts[3])
+	case len(parts) == 3 && parts[0] == "channels" && parts[2] == "typing" && r.Method == http.MethodPost:
+		s.typing++
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 3 && parts[0] == "channels" && parts[2] == "webhooks" && r.Method == http.MethodPost:
+		s.createWebhook(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "webhooks" && r.Method == http.MethodDelete:
+		s.deleteWebhook(w, parts[1])
+	case len(parts) == 3 && parts[0] == "webhooks" && r.Method == http.MethodPost:
+		s.executeWebhook(w, r, parts[1], parts[2])
+	case len(parts) == 5 && parts[0] == "webhooks" && parts[3] == "messages" && parts[4] == "@original" && r.Method == http.MethodPatch:
+		s.editOriginal(w, r, parts[1], parts[2])
+	case len(parts) == 4 && parts[0] == "interactions" && parts[3] == "callback" && r.Method == http.MethodPost:
+		s.callback(w, r, parts[2])
+	default:
+		writeError(w, http.StatusNotFound, 0, "404: Not Found")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "message": message})
+}
+
+// readPost decodes a json body, or the payload_json and files of a
+// multipart body, into v.
+func readPost(r *http.Request, v interface{}) ([]File, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	if mediaType != "multipart/form-data" {
+		return nil, json.NewDecoder(r.Body).Decode(v)
+	}
+
+	var files []File
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == "payload_json" {
+			err = json.Unmarshal(data, v)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		files = append(files, File{
+			Name:        part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Data:        data,
+		})
+	}
+}
+
+func readMessage(r *http.Request, token string) (Post, error) {
+	var payload struct {
+		discordgo.WebhookParams
+		Components []json.RawMessage `json:"components"`
+	}
+	files, err := readPost(r, &payload)
+	if err != nil {
+		return Post{}, err
+	}
+	return Post{
+		Token:      token,
+		Params:     payload.WebhookParams,
+		Components: payload.Components,
+		Files:      files,
+	}, nil
+}
+
+// commandsRoute lists or bulk overwrites the commands of
+// applications/<app>/commands and applications/<app>/guilds/<guild>/commands.
+func (s *Server) commandsRoute(w http.ResponseWriter, r *http.Request, parts []string) {
+	guildID := ""
+	switch {
+	case parts[1] != AppID:
+		writeError(w, http.StatusNotFound, 10002, "Unknown Application")
+		return
+	case len(parts) == 5 && parts[2] == "guilds":
+		guildID = parts[3]
+	case len(parts) != 3:
+		writeError(w, http.StatusNotFound, 0, "404: Not Found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, append([]*discordgo.ApplicationCommand{}, s.commands[guildID]...))
+	case http.MethodPut:
+		var cmds []*discordgo.ApplicationCommand
+		_, err := readPost(r, &cmds)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, 50035, err.Error())
+			return
+		}
+		for _, cmd := range cmds {
+			cmd.ID = s.id()
+			cmd.ApplicationID = AppID
+			cmd.GuildID = guildID
+		}
+		s.commands[guildID] = cmds
+		writeJSON(w, append([]*discordgo.ApplicationCommand{}, cmds...))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+	}
+}
+
+func (s *Server) getGuild(w http.ResponseWriter, guildID string) {
+	guild, ok := s.guilds[guildID]
+	if !ok {
+		writeError(w, http.StatusNotFound, 10004, "Unknown Guild")
+		return
+	}
+	writeJSON(w, guild)
+}
+
+func (s *Server) getRoles(w http.ResponseWriter, guildID string) {
+	guild, ok := s.guilds[guildID]
+	if !ok {
+		writeError(w, http.StatusNotFound, 10004, "Unknown Guild")
+		return
+	}
+	writeJSON(w, guild.Roles)
+}
+
+func (s *Server) getMember(w http.ResponseWriter, guildID string, userID string) {
+	if _, ok := s.guilds[guildID]; !ok {
+		writeError(w, http.StatusNotFound, 10004, "Unknown Guild")
+		return
+	}
+	member, ok := s.members[guildID][userID]
+	if !ok {
+		writeError(w, http.StatusNotFound, 10007, "Unknown Member")
+		return
+	}
+	writeJSON(w, member)
+}
+
+func (s *Server) getChannel(w http.ResponseWriter, channelID string) {
+	channel, ok := s.channels[channelID]
+	if !ok {
+		writeError(w, http.StatusNotFound, 10003, "Unknown Channel")
+		return
+	}
+	writeJSON(w, channel)
+}
+
+func (s *Server) getMessages(w http.ResponseWriter, r *http.Request, channelID string) {
+	if _, ok := s.channels[channelID]; !ok {
+		writeError(w, http.StatusNotFound, 10003, "Unknown Channel")
+		return
+	}
+
+	limit := 50
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+		limit = n
+	}
+	if limit < 1 || limit > 100 {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+		return
+	}
+
+	before := r.URL.Query().Get("before")
+	page := make([]*discordgo.Message, 0, limit)
+	for _, msg := range s.messages[channelID] {
+		if len(page) == limit {
+			break
+		}
+		if before != "" && !snowflakeLess(msg.ID, before) {
+			continue
+		}
+		page = append(page, msg)
+	}
+	writeJSON(w, page)
+}
+
+// messageJSON is a message as discord returns it, with the components kept
+// as they were posted.
+type messageJSON struct {
+	*discordgo.Message
+	Components []json.RawMessage `json:"components"`
+}
+
+func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request, channelID string) {
+	if _, ok := s.channels[channelID]; !ok {
+		writeError(w, http.StatusNotFound, 10003, "Unknown Channel")
+		return
+	}
+
+	post, err := readMessage(r, "")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 50035, err.Error())
+		return
+	}
+
+	msg := &discordgo.Message{
+		ID:        Snowflake(time.Now(), int(s.nextID)),
+		ChannelID: channelID,
+		Content:   post.Params.Content,
+		Embeds:    post.Params.Embeds,
+		Author:    &discordgo.User{ID: AppID, Username: "Raku", Bot: true},
+		Timestamp: time.Now(),
+	}
+	s.nextID++
+	s.messages[channelID] = append([]*discordgo.Message{msg}, s.messages[channelID]...)
+	s.sent = append(s.sent, Message{ChannelID: channelID, MessageID: msg.ID, Post: post})
+	writeJSON(w, messageJSON{Message: msg, Components: post.Components})
+}
+
+func (s *Server) editMessage(w http.ResponseWriter, r *http.Request, channelID string, messageID string) {
+	var msg *discordgo.Message
+	for _, m := range s.messages[channelID] {
+		if m.ID == messageID {
+			msg = m
+		}
+	}
+	if msg == nil {
+		writeError(w, http.StatusNotFound, 10008, "Unknown Message")
+		return
+	}
+
+	post, err := readMessage(r, "")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 50035, err.Error())
+		return
+	}
+
+	if post.Params.Content != "" {
+		msg.Content = post.Params.Content
+	}
+	msg.Embeds = post.Params.Embeds
+	s.edited = append(s.edited, Message{ChannelID: channelID, MessageID: messageID, Post: post})
+	writeJSON(w, messageJSON{Message: msg, Components: post.Components})
+}
+
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request, channelID string) {
+	channel, ok := s.channels[channelID]
+	if !ok {
+		writeError(w, http.StatusNotFound, 10003, "Unknown Channel")
+		return
+	}
+
+	var data struct {
+		Name   string `json:"name"`
+		Avatar string `json:"avatar"`
+	}
+	_, err := readPost(r, &data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 50035, err.Error())
+		return
+	}
+
+	webhook := &discordgo.Webhook{
+		ID:        s.id(),
+		Type:      discordgo.WebhookTypeIncoming,
+		GuildID:   channel.GuildID,
+		ChannelID: channelID,
+		Name:      data.Name,
+		Avatar:    data.Avatar,
+		Token:     "webhook-token-" + s.id(),
+	}
+	s.webhooks[webhook.ID] = webhook
+	writeJSON(w, webhook)
+}
+
+func (s *Server) deleteWebhook(w http.ResponseWriter, webhookID string) {
+	if _, ok := s.webhooks[webhookID]; !ok {
+		writeError(w, http.StatusNotFound, 10015, "Unknown Webhook")
+		return
+	}
+	delete(s.webhooks, webhookID)
+	s.deleted = append(s.deleted, webhookID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// executeWebhook handles both webhook messages and interaction followups,
+// which share the endpoint.
+func (s *Server) executeWebhook(w http.ResponseWriter, r *http.Request, webhookID string, token string) {
+	post, err := readMessage(r, token)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 50035, err.Error())
+		return
+	}
+
+	if webhookID == AppID {
+		if !s.acked[token] {
+			writeError(w, http.StatusNotFound, 10015, "Unknown Webhook")
+			return
+		}
+		s.followups = append(s.followups, post)
+		writeJSON(w, &discordgo.Message{ID: s.id(), Content: post.Params.Content, Timestamp: time.Now()})
+		return
+	}
+
+	webhook, ok := s.webhooks[webhookID]
+	if !ok || webhook.Token != token {
+		writeError(w, http.StatusNotFound, 10015, "Unknown Webhook")
+		return
+	}
+
+	threadID := r.URL.Query().Get("thread_id")
+	s.executions = append(s.executions, Execution{WebhookID: webhookID, ThreadID: threadID, Post: post})
+
+	channelID := webhook.ChannelID
+	if threadID != "" {
+		channelID = threadID
+	}
+	msg := &discordgo.Message{
+		ID:        s.id(),
+		ChannelID: channelID,
+		Content:   post.Params.Content,
+		Author:    &discordgo.User{ID: webhook.ID, Username: post.Params.Username, Bot: true},
+		Timestamp: time.Now(),
+		WebhookID: webhook.ID,
+	}
+	if r.URL.Query().Get("wait") != "true" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, msg)
+}
+
+func (s *Server) editOriginal(w http.ResponseWriter, r *http.Request, appID string, token string) {
+	if appID != AppID {
+		writeError(w, http.StatusNotFound, 10015, "Unknown Webhook")
+		return
+	}
+	if !s.acked[token] {
+		writeError(w, http.StatusNotFound, 10008, "Unknown Message")
+		return
+	}
+
+	post, err := readMessage(r, token)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 50035, err.Error())
+		return
+	}
+	s.edits = append(s.edits, post)
+	writeJSON(w, &discordgo.Message{ID: "@original", Content: post.Params.Content, Timestamp: time.Now()})
+}
+
+func (s *Server) callback(w http.ResponseWriter, r *http.Request, token string) {
+	if s.acked[token] {
+		writeError(w, http.StatusBadRequest, 40060, "Interaction has already been acknowledged.")
+		return
+	}
+
+	var res struct {
+		Type discordgo.InteractionResponseType `json:"type"`
+		Data *struct {
+			discordgo.InteractionResponseData
+			Components []json.RawMessage `json:"components"`
+		} `json:"data"`
+	}
+	files, err := readPost(r, &res)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 50035, err.Error())
+		return
+	}
+
+	callback := Callback{Token: token, Type: res.Type, Files: files}
+	if res.Data != nil {
+		callback.Data = &res.Data.InteractionResponseData
+		callback.Components = res.Data.Components
+	}
+	s.acked[token] = true
+	s.callbacks = append(s.callbacks, callback)
+	w.WriteHeader(http.StatusNoContent)
+}
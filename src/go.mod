@@ -3,9 +3,12 @@ module Raku
 go 1.21.4
 
 require (
-	github.com/bwmarrin/discordgo v0.27.1 // indirect
-	github.com/go-yaml/yaml v2.1.0+incompatible // indirect
-	github.com/gomarkdown/markdown v0.0.0-20231115200524-a660076da3fd // indirect
+	github.com/bwmarrin/discordgo v0.27.1
+	github.com/go-yaml/yaml v2.1.0+incompatible
+	github.com/gomarkdown/markdown v0.0.0-20231115200524-a660076da3fd
+)
+
+require (
 	github.com/gorilla/websocket v1.4.2 // indirect
 	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b // indirect
 	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68 // indirect
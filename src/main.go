@@ -2,32 +2,54 @@ package main
 
 import (
 	"Raku/botctx"
+	"Raku/config"
+	"embed"
+	"io/fs"
 	"log"
 	"os"
-	"strings"
 )
 
+var cfg = config.Default()
+
+//go:embed locales/*.yaml
+var locales embed.FS
+
 func main() {
 	args := os.Args[1:]
 
-	_, fp := os.Stat("token")
+	// Empty means config.DefaultPath, which unlike a given path may be
+	// missing.
+	path := ""
 
-	if len(args) == 0 && fp != nil {
-		log.Fatalln("error: please provide bot token. you can pass token as command line argument or make a file named token with the bot token in it")
+	if len(args) > 0 {
+		path = args[0]
+	} else if env := os.Getenv("RAKU_CONFIG"); env != "" {
+		path = env
 	}
 
-	token := ""
+	loaded, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	cfg = loaded
 
-	if len(args) > 0 {
-		token = args[0]
-	} else {
-		content, _ := os.ReadFile("token")
-		token = string(content)
+	botctx.SetupLogging(cfg.Log)
+
+	localeFS, _ := fs.Sub(locales, "locales")
+	err = botctx.LoadLocales(localeFS)
+	if err != nil {
+		log.Fatalf("error: %v", err)
 	}
 
-	botctx.RegisterApplicationCommand(SeasonalCommand)
-	botctx.RegisterApplicationCommand(SearchAnimeCommand)
-	botctx.RegisterApplicationCommand(SearchMangaCommand)
+	botctx.Use(botctx.Recover(), botctx.Logging(), botctx.Timing())
+
+	botctx.RegisterApplicationCommand(AnimeCommand)
+	botctx.RegisterApplicationCommand(MangaCommand)
 	botctx.RegisterApplicationCommand(MigrateCommand)
-	botctx.Login(strings.TrimSpace(token))
+	botctx.RegisterApplicationCommand(AnimeLookupCommand)
+	botctx.RegisterApplicationCommand(MigrateFromHereCommand)
+	botctx.RegisterApplicationCommand(botctx.HelpCommand)
+	botctx.RegisterModal(botctx.ComponentID("migrate", "file"), migrateFileSubmit)
+	botctx.RegisterModal(migrateFromHereName, migrateFromHereSubmit)
+	botctx.Login(cfg)
 }
@@ -0,0 +1,292 @@
+// Package metrics keeps counters, gauges and histograms in memory and writes
+// them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds in seconds of latency histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var registry struct {
+	sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+func register(name string, m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if registry.names == nil {
+		registry.names = make(map[string]bool)
+	}
+	if registry.names[name] {
+		panic(fmt.Sprintf("metrics: %v registered twice", name))
+	}
+	registry.names[name] = true
+	registry.metrics = append(registry.metrics, m)
+}
+
+// Write writes every metric in the order they were created.
+func Write(w io.Writer) {
+	registry.Lock()
+	metrics := registry.metrics
+	registry.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics to Prometheus.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", d.name, helpEscaper.Replace(d.help), d.name, d.kind)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// series formats the labels of a series, extra is appended as is, e.g. for
+// the le label of histogram buckets.
+func (d desc) series(name string, values []string, extra string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for idx, value := range values {
+		pairs = append(pairs, d.labels[idx]+`="`+labelEscaper.Replace(value)+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %v has labels %v, got %v values", d.name, d.labels, len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type sample struct {
+	values []string
+	value  float64
+}
+
+// vec holds one float per combination of label values.
+type vec struct {
+	desc
+	mutex   sync.Mutex
+	samples map[string]*sample
+}
+
+func (v *vec) add(delta float64, values []string) {
+	key := v.key(values)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{values: append([]string(nil), values...)}
+		v.samples[key] = s
+	}
+	s.value += delta
+}
+
+func (v *vec) set(value float64, values []string) {
+	key := v.key(values)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{values: append([]string(nil), values...)}
+		v.samples[key] = s
+	}
+	s.value = value
+}
+
+func (v *vec) write(w io.Writer) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.header(w)
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.samples[key]
+		fmt.Fprintf(w, "%v %v\n", v.series(v.name, s.values, ""), formatFloat(s.value))
+	}
+}
+
+// CounterVec is a count that only goes up, per combination of labels.
+type CounterVec struct {
+	vec
+}
+
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec{desc: desc{name, help, "counter", labels}, samples: make(map[string]*sample)}}
+	register(name, c)
+	return c
+}
+
+func (c *CounterVec) Inc(values ...string) {
+	c.add(1, values)
+}
+
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: counter %v cannot decrease", c.name))
+	}
+	c.add(delta, values)
+}
+
+// GaugeVec is a value that goes up and down, per combination of labels.
+type GaugeVec struct {
+	vec
+}
+
+func NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{vec{desc: desc{name, help, "gauge", labels}, samples: make(map[string]*sample)}}
+	register(name, g)
+	return g
+}
+
+func (g *GaugeVec) Set(value float64, values ...string) {
+	g.set(value, values)
+}
+
+func (g *GaugeVec) Add(delta float64, values ...string) {
+	g.add(delta, values)
+}
+
+// GaugeFunc is a gauge read from fn whenever the metrics are written.
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+func NewGaugeFunc(name string, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help, kind: "gauge"}, fn: fn}
+	register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.header(w)
+	fmt.Fprintf(w, "%v %v\n", g.name, formatFloat(g.fn()))
+}
+
+type histogram struct {
+	values []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec counts observations into buckets, per combination of labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+
+	mutex      sync.Mutex
+	histograms map[string]*histogram
+}
+
+// NewHistogramVec creates a histogram with the given upper bounds, which must
+// be sorted. A +Inf bucket is always added.
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:       desc{name, help, "histogram", labels},
+		buckets:    buckets,
+		histograms: make(map[string]*histogram),
+	}
+	register(name, h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	key := h.key(values)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	hist, ok := h.histograms[key]
+	if !ok {
+		hist = &histogram{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.histograms[key] = hist
+	}
+	for idx, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[idx]++
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.header(w)
+	keys := make([]string, 0, len(h.histograms))
+	for key := range h.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		hist := h.histograms[key]
+		for idx, bound := range h.buckets {
+			le := `le="` + formatFloat(bound) + `"`
+			fmt.Fprintf(w, "%v %v\n", h.series(h.name+"_bucket", hist.values, le), hist.counts[idx])
+		}
+		fmt.Fprintf(w, "%v %v\n", h.series(h.name+"_bucket", hist.values, `le="+Inf"`), hist.count)
+		fmt.Fprintf(w, "%v %v\n", h.series(h.name+"_sum", hist.values, ""), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%v %v\n", h.series(h.name+"_count", hist.values, ""), hist.count)
+	}
+}
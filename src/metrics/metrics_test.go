@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func written(m metric) string {
+	var builder strings.Builder
+	m.write(&builder)
+	return builder.String()
+}
+
+func TestCounterVec(t *testing.T) {
+	c := NewCounterVec("test_counter_total", "Counts things.", "command", "type")
+	c.Inc("anime search", "command")
+	c.Inc("anime search", "command")
+	c.Add(0.5, "anime search", "command")
+	c.Add(1e6, "migrate", "modal")
+	c.Inc(`a "quoted" \ path`+"\nline", "component")
+
+	want := `# HELP test_counter_total Counts things.
+# TYPE test_counter_total counter
+test_counter_total{command="a \"quoted\" \\ path\nline",type="component"} 1
+test_counter_total{command="anime search",type="command"} 2.5
+test_counter_total{command="migrate",type="modal"} 1e+06
+`
+	if got := written(c); got != want {
+		t.Errorf("wrote\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestCounterVecPanics(t *testing.T) {
+	c := NewCounterVec("test_counter_panics_total", "Panics.", "command")
+
+	for name, fn := range map[string]func(){
+		"negative":       func() { c.Add(-1, "anime") },
+		"missing labels": func() { c.Inc() },
+		"twice":          func() { NewCounterVec("test_counter_panics_total", "Again.") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+func TestGauges(t *testing.T) {
+	g := NewGaugeVec("test_gauge", "Goes up\nand down \\ again.", "state")
+	g.Set(3, "running")
+	g.Add(-1, "running")
+	g.Set(math.Inf(1), "unbounded")
+
+	want := `# HELP test_gauge Goes up\nand down \\ again.
+# TYPE test_gauge gauge
+test_gauge{state="running"} 2
+test_gauge{state="unbounded"} +Inf
+`
+	if got := written(g); got != want {
+		t.Errorf("wrote\n%v\nwant\n%v", got, want)
+	}
+
+	f := NewGaugeFunc("test_gauge_func", "Read on write.", func() float64 { return 0.25 })
+	want = `# HELP test_gauge_func Read on write.
+# TYPE test_gauge_func gauge
+test_gauge_func 0.25
+`
+	if got := written(f); got != want {
+		t.Errorf("wrote\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	h := NewHistogramVec("test_duration_seconds", "Durations.", []float64{0.1, 0.5, 1}, "command")
+	for _, value := range []float64{0.05, 0.1, 0.3, 2} {
+		h.Observe(value, "anime")
+	}
+	h.Observe(0.7, "help")
+
+	want := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{command="anime",le="0.1"} 2
+test_duration_seconds_bucket{command="anime",le="0.5"} 3
+test_duration_seconds_bucket{command="anime",le="1"} 3
+test_duration_seconds_bucket{command="anime",le="+Inf"} 4
+test_duration_seconds_sum{command="anime"} 2.45
+test_duration_seconds_count{command="anime"} 4
+test_duration_seconds_bucket{command="help",le="0.1"} 0
+test_duration_seconds_bucket{command="help",le="0.5"} 0
+test_duration_seconds_bucket{command="help",le="1"} 1
+test_duration_seconds_bucket{command="help",le="+Inf"} 1
+test_duration_seconds_sum{command="help"} 0.7
+test_duration_seconds_count{command="help"} 1
+`
+	if got := written(h); got != want {
+		t.Errorf("wrote\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	NewGaugeFunc("test_handler_up", "Up.", func() float64 { return 1 })
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "\ntest_handler_up 1\n") {
+		t.Errorf("body misses the gauge:\n%v", rec.Body.String())
+	}
+}
@@ -2,9 +2,16 @@ package main
 
 import (
 	"Raku/botctx"
+	"Raku/config"
+	"Raku/metrics"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/http"
 	"strings"
 	"time"
 
@@ -14,31 +21,72 @@ import (
 	"github.com/gomarkdown/markdown/parser"
 )
 
+type migrateChannelArgs struct {
+	Channel *discordgo.Channel `option:"channel" description:"name of the channel to migrate" required:"true" channel:"text,public_thread"`
+	Mention *bool              `option:"mention" description:"mention original author in migrated messages" default:"true"`
+}
+
+type migrateFileArgs struct {
+	Filename string `option:"filename" description:"name for the migrate html file, leave empty to fill in a form" max:"100"`
+}
+
+const migrateFromHereName = "Migrate from here"
+
+var (
+	migratedMessages = metrics.NewCounterVec("raku_migrate_messages_total", "Messages copied by migrations, by target.", "target")
+	migrations       = metrics.NewCounterVec("raku_migrations_total", "Finished migrations by target and result.", "target", "result")
+)
+
+var (
+	migrateDefaultPermissions int64 = discordgo.PermissionManageWebhooks
+	migrateDMPermission             = false
+)
+
 var MigrateCommand = botctx.CommandDesc{
 	Name:        "migrate",
 	Description: "migrate message from this channel to another channel or html file",
-	Options: []*discordgo.ApplicationCommandOption{
-		{
-			Type:        discordgo.ApplicationCommandOptionChannel,
-			Name:        "channel",
-			Description: "name of the channel to migrate",
-			Required:    false,
-		},
+	Cooldown: config.Cooldown{
+		Scope:       config.ScopeGuild,
+		Concurrency: 1,
+	},
+	DefaultMemberPermissions: &migrateDefaultPermissions,
+	DMPermission:             &migrateDMPermission,
+	MemberPermissions:        discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory,
+	BotPermissions:           discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory,
+	Subcommands: []botctx.CommandDesc{
 		{
-			Type:        discordgo.ApplicationCommandOptionString,
-			Name:        "filename",
-			Description: "name for the migrate html file",
-			Required:    false,
+			Name:              "channel",
+			Description:       "migrate message from this channel to another channel",
+			Options:           botctx.OptionsOf[migrateChannelArgs](),
+			Func:              botctx.Bind(migrateChannel),
+			MemberPermissions: discordgo.PermissionManageWebhooks,
+			Check:             migrateCheckTarget,
+			Examples:          []string{"/migrate channel channel:#archive", "/migrate channel channel:#archive mention:false"},
 		},
 		{
-			Type:        discordgo.ApplicationCommandOptionBoolean,
-			Name:        "mention",
-			Description: "mention original author in migrated messages",
-			Required:    false,
+			Name:        "file",
+			Description: "migrate message from this channel to a json or html file",
+			Options:     botctx.OptionsOf[migrateFileArgs](),
+			Func:        botctx.Bind(migrateFile),
+			Examples:    []string{"/migrate file", "/migrate file filename:archive"},
 		},
 	},
-	Func:        migrate,
-	Interaction: nil,
+}
+
+// MigrateFromHereCommand migrates a message and everything sent after it to
+// a file.
+var MigrateFromHereCommand = botctx.CommandDesc{
+	Type: discordgo.MessageApplicationCommand,
+	Name: migrateFromHereName,
+	Func: migrateFromHere,
+	Cooldown: config.Cooldown{
+		Scope:       config.ScopeGuild,
+		Concurrency: 1,
+	},
+	DefaultMemberPermissions: &migrateDefaultPermissions,
+	DMPermission:             &migrateDMPermission,
+	MemberPermissions:        discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory,
+	BotPermissions:           discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory,
 }
 
 var (
@@ -284,62 +332,238 @@ func migrateHtmlEnd(builder *strings.Builder) {
 	builder.WriteString("</html>")
 }
 
-func migrateUpdateResponse(session *discordgo.Session, interaction *discordgo.Interaction, desc string, color int) bool {
+func migrateUpdateResponse(ctx context.Context, desc string, color int) bool {
+	// The token expired, keep migrating without reporting progress.
+	if ctx.Err() != nil {
+		return true
+	}
+
 	edit := &discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{
 			{
-				Title:       "Migration",
+				Title:       botctx.Tr(ctx, "migrate.title"),
 				Description: desc,
 				Type:        discordgo.EmbedTypeRich,
 				Color:       color,
 			},
 		},
 	}
-	_, err := session.InteractionResponseEdit(interaction, edit)
+	_, err := botctx.ResponderFrom(ctx).Edit(edit)
 	if err != nil {
-		log.Printf("error: migrateUpdateResponse: %+v\n", err)
+		slog.ErrorContext(ctx, "migrateUpdateResponse", "err", err)
 		return false
 	}
 	return true
 }
 
-func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
-	var filename string = ""
-	var channel *discordgo.Channel
-	mention := true
+// migrateDownload fetches an attachment to be reuploaded.
+func migrateDownload(ctx context.Context, session botctx.Session, src *discordgo.MessageAttachment) (*discordgo.File, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", src.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := session.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New(res.Status)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &discordgo.File{
+		Name:        src.Filename,
+		ContentType: src.ContentType,
+		Reader:      bytes.NewReader(data),
+	}, nil
+}
+
+type migrateRequest struct {
+	channel  *discordgo.Channel
+	filename string
+	mention  bool
+	after    time.Time
+	before   time.Time
+}
 
+type migrateForm struct {
+	Filename string    `modal:"filename"`
+	After    time.Time `modal:"after"`
+	Before   time.Time `modal:"before"`
+}
+
+// The webhook is created in the parent of a thread, and permissions in a
+// thread follow its parent, so the target is checked there.
+func migrateCheckTarget(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate) error {
 	data := i.ApplicationCommandData()
-	for _, option := range data.Options {
-		if option.Name == "filename" {
-			filename = option.StringValue()
-		} else if option.Name == "channel" {
-			channel = option.ChannelValue(session)
-		} else if option.Name == "mention" {
-			mention = option.BoolValue()
+
+	for _, option := range botctx.CommandOptions(data) {
+		if option.Name != "channel" {
+			continue
+		}
+
+		target := option.ChannelValue(nil).ID
+		if data.Resolved != nil && data.Resolved.Channels[target] != nil {
+			channel := data.Resolved.Channels[target]
+			if channel.IsThread() {
+				target = channel.ParentID
+			}
+		}
+
+		user := botctx.InteractionUser(i)
+		err := botctx.RequireChannelPermissions(session, "You", user.ID, target, discordgo.PermissionViewChannel|discordgo.PermissionSendMessages)
+		if err != nil {
+			return err
 		}
+
+		return botctx.RequireChannelPermissions(session, "I", session.BotUser().ID, target, discordgo.PermissionViewChannel|discordgo.PermissionManageWebhooks)
 	}
 
-	if filename == "" && channel == nil {
+	return nil
+}
+
+func migrateChannel(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, args *migrateChannelArgs) {
+	req := migrateRequest{
+		channel: args.Channel,
+		mention: true,
+	}
+	if args.Mention != nil {
+		req.mention = *args.Mention
+	}
+	doMigrate(ctx, session, i, req)
+}
+
+func migrateFile(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, args *migrateFileArgs) {
+	if args.Filename != "" {
+		doMigrate(ctx, session, i, migrateRequest{filename: args.Filename})
+		return
+	}
+
+	err := botctx.OpenModal(ctx, botctx.CustomID(botctx.ComponentID("migrate", "file"), nil), botctx.Tr(ctx, "migrate.modal.title"),
+		discordgo.TextInput{
+			CustomID:    "filename",
+			Label:       botctx.Tr(ctx, "migrate.modal.filename"),
+			Style:       discordgo.TextInputShort,
+			Placeholder: "archive.html",
+			Required:    true,
+			MaxLength:   100,
+		},
+		discordgo.TextInput{
+			CustomID:    "after",
+			Label:       botctx.Tr(ctx, "migrate.modal.after"),
+			Style:       discordgo.TextInputShort,
+			Placeholder: botctx.ModalDateLayout,
+			MaxLength:   len(botctx.ModalDateLayout),
+		},
+		discordgo.TextInput{
+			CustomID:    "before",
+			Label:       botctx.Tr(ctx, "migrate.modal.before"),
+			Style:       discordgo.TextInputShort,
+			Placeholder: botctx.ModalDateLayout,
+			MaxLength:   len(botctx.ModalDateLayout),
+		},
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "migrateFile", "err", err)
+	}
+}
+
+func migrateFileSubmit(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, form *migrateForm, args []string) {
+	if !form.After.IsZero() && !form.Before.IsZero() && !form.Before.After(form.After) {
 		var res = &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Embeds: []*discordgo.MessageEmbed{
 					{
-						Title:       "Migration Failed",
-						Description: "One of channel name or file name is required",
+						Title:       botctx.Tr(ctx, "migrate.failed"),
+						Description: botctx.Tr(ctx, "migrate.date_order"),
 						Type:        discordgo.EmbedTypeRich,
-						Color:       0xdd1111,
+						Color:       cfg.Colors.Error,
 					},
 				},
 				Flags: discordgo.MessageFlagsEphemeral,
 			},
 		}
-		session.InteractionRespond(i.Interaction, res)
+		botctx.ResponderFrom(ctx).Respond(res)
 		return
 	}
 
+	req := migrateRequest{
+		filename: form.Filename,
+		after:    form.After,
+		before:   form.Before,
+	}
+	doMigrate(ctx, session, i, req)
+}
+
+type migrateFromHereForm struct {
+	Filename string `modal:"filename"`
+}
+
+type migrateFromHereRef struct {
+	MessageID string
+}
+
+func migrateFromHere(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate) {
+	ref := migrateFromHereRef{MessageID: i.ApplicationCommandData().TargetID}
+
+	err := botctx.OpenModal(ctx, botctx.CustomID(migrateFromHereName, ref), botctx.Tr(ctx, "migrate.modal.title"),
+		discordgo.TextInput{
+			CustomID:    "filename",
+			Label:       botctx.Tr(ctx, "migrate.modal.filename"),
+			Style:       discordgo.TextInputShort,
+			Placeholder: "archive.html",
+			Required:    true,
+			MaxLength:   100,
+		},
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "migrateFromHere", "err", err)
+	}
+}
+
+func migrateFromHereSubmit(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, form *migrateFromHereForm, args []string) {
+	var after time.Time
+	var err error = botctx.ErrCustomIDMalformed
+	if len(args) == 1 {
+		after, err = discordgo.SnowflakeTimestamp(args[0])
+	}
+	if err != nil {
+		botctx.ResponderFrom(ctx).Respond(&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Embeds: []*discordgo.MessageEmbed{
+					{
+						Title:       botctx.Tr(ctx, "migrate.failed"),
+						Description: botctx.Tr(ctx, "migrate.from_not_found"),
+						Type:        discordgo.EmbedTypeRich,
+						Color:       cfg.Colors.Error,
+					},
+				},
+				Flags: discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+
+	req := migrateRequest{
+		filename: form.Filename,
+		after:    after,
+	}
+	doMigrate(ctx, session, i, req)
+}
+
+func doMigrate(ctx context.Context, session botctx.Session, i *discordgo.InteractionCreate, req migrateRequest) {
+	filename := req.filename
+	channel := req.channel
+	mention := req.mention
+
 	if len(filename) > 0 {
-		if !strings.HasSuffix(filename, ".html") && !strings.HasPrefix(filename, ".json") {
+		if !strings.HasSuffix(filename, ".html") && !strings.HasSuffix(filename, ".json") {
 			filename += ".json"
 		}
 	}
@@ -350,15 +574,15 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 			Data: &discordgo.InteractionResponseData{
 				Embeds: []*discordgo.MessageEmbed{
 					{
-						Title:       "Migration Failed",
-						Description: fmt.Sprintf("Invalid channel %+v only text channel are accepted", channel.Name),
-						Color:       0xdd1111,
+						Title:       botctx.Tr(ctx, "migrate.failed"),
+						Description: botctx.Tr(ctx, "migrate.invalid_channel", channel.Name),
+						Color:       cfg.Colors.Error,
 					},
 				},
 				Flags: discordgo.MessageFlagsEphemeral,
 			},
 		}
-		session.InteractionRespond(i.Interaction, res)
+		botctx.ResponderFrom(ctx).Respond(res)
 		return
 	}
 
@@ -368,21 +592,26 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 			Data: &discordgo.InteractionResponseData{
 				Embeds: []*discordgo.MessageEmbed{
 					{
-						Title:       "Migration",
-						Description: "Downloading messages...",
+						Title:       botctx.Tr(ctx, "migrate.title"),
+						Description: botctx.Tr(ctx, "migrate.downloading"),
 						Type:        discordgo.EmbedTypeRich,
-						Color:       0x11dddd,
+						Color:       cfg.Colors.Info,
 					},
 				},
 			},
 		}
-		err := session.InteractionRespond(i.Interaction, res)
+		err := botctx.ResponderFrom(ctx).Respond(res)
 		if err != nil {
-			log.Printf("error: migrate: %+v\n", err)
+			slog.ErrorContext(ctx, "migrate", "err", err)
 			return
 		}
 	}
 
+	// Large channels take longer than the interaction token lasts, the
+	// migration itself only stops when the bot shuts down.
+	work, cancel := botctx.Detach(ctx)
+	defer cancel()
+
 	beforeID := ""
 
 	var msgs = make([]*discordgo.Message, 0, 1024*1024)
@@ -390,9 +619,12 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 	var start = time.Now()
 
 	for {
-		downs, err := session.ChannelMessages(i.ChannelID, 100, beforeID, "", "")
+		downs, err := session.ChannelMessages(i.ChannelID, 100, beforeID, "", "", discordgo.WithContext(work))
+		if work.Err() != nil {
+			return
+		}
 		if err != nil {
-			if !migrateUpdateResponse(session, i.Interaction, "Failed to download message beforeID: "+beforeID, 0xff2222) {
+			if !migrateUpdateResponse(ctx, botctx.Tr(ctx, "migrate.download_failed", beforeID), cfg.Colors.Error) {
 				return
 			}
 		}
@@ -402,17 +634,21 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 		beforeID = downs[len(downs)-1].ID
 		msgs = append(msgs, downs...)
 
-		if time.Since(start) > 700*time.Millisecond {
+		if !req.after.IsZero() && downs[len(downs)-1].Timestamp.Before(req.after) {
+			break
+		}
+
+		if time.Since(start) > cfg.Migrate.ProgressInterval {
 			start = time.Now()
-			desc := fmt.Sprintf("Downloading messages (%v)...", len(msgs))
-			if !migrateUpdateResponse(session, i.Interaction, desc, 0x11dddd) {
+			desc := botctx.Tr(ctx, "migrate.downloading_count", len(msgs))
+			if !migrateUpdateResponse(ctx, desc, cfg.Colors.Info) {
 				return
 			}
 		}
 	}
 
-	desc := fmt.Sprintf("Downloaded (%v) messages. Filtering messages...", len(msgs))
-	if !migrateUpdateResponse(session, i.Interaction, desc, 0x11dddd) {
+	desc := botctx.Tr(ctx, "migrate.downloaded", len(msgs))
+	if !migrateUpdateResponse(ctx, desc, cfg.Colors.Info) {
 		return
 	}
 
@@ -425,18 +661,24 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 		if len(msgs[index].Content) == 0 && len(msgs[index].Attachments) == 0 {
 			continue
 		}
+		if !req.after.IsZero() && msgs[index].Timestamp.Before(req.after) {
+			continue
+		}
+		if !req.before.IsZero() && !msgs[index].Timestamp.Before(req.before) {
+			continue
+		}
 		filtered = append(filtered, msgs[index])
 
-		if time.Since(start) > 700*time.Millisecond {
+		if time.Since(start) > cfg.Migrate.ProgressInterval {
 			start = time.Now()
-			desc := fmt.Sprintf("Filtering messages %v of %v...", index+1, len(msgs))
-			if !migrateUpdateResponse(session, i.Interaction, desc, 0x11dddd) {
+			desc := botctx.Tr(ctx, "migrate.filtering", index+1, len(msgs))
+			if !migrateUpdateResponse(ctx, desc, cfg.Colors.Info) {
 				return
 			}
 		}
 	}
 
-	if !migrateUpdateResponse(session, i.Interaction, "Archiving messages...", 0x11dddd) {
+	if !migrateUpdateResponse(ctx, botctx.Tr(ctx, "migrate.archiving"), cfg.Colors.Info) {
 		return
 	}
 
@@ -444,10 +686,12 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 	var fileMigrateErr error = nil
 
 	if channel != nil {
-		desc = fmt.Sprintf("Filtering complete. Migrating %+v messages to channel: <#%+v>...", len(filtered), channel.ID)
-		migrateUpdateResponse(session, i.Interaction, desc, 0x11dddd)
+		desc = botctx.Tr(ctx, "migrate.migrating", len(filtered), channel.ID)
+		migrateUpdateResponse(ctx, desc, cfg.Colors.Info)
 
 		var webhook *discordgo.Webhook
+		var attachmentErr error
+		failedAttachments := 0
 
 		parent := channel.ID
 
@@ -455,7 +699,12 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 			parent = channel.ParentID
 		}
 
-		webhook, channelMigrateErr = session.WebhookCreate(parent, "migration-webhook", session.State.User.AvatarURL(""))
+		webhook, channelMigrateErr = session.WebhookCreate(parent, "migration-webhook", session.BotUser().AvatarURL(""), discordgo.WithContext(work))
+		if channelMigrateErr == nil {
+			// Not bound to ctx, the webhook has to go even when the
+			// migration was cancelled.
+			defer session.WebhookDelete(webhook.ID)
+		}
 
 		for idx, msg := range filtered {
 			if channelMigrateErr != nil {
@@ -464,22 +713,20 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 
 			content := msg.Content
 			if mention {
-				content += "\n\n- *Original Author*: <@" + msg.Author.ID + ">\n\n"
+				content += botctx.Translate(botctx.GuildLocale(i.Interaction), "migrate.original_author", msg.Author.ID)
 			}
 
 			attachments := make([]*discordgo.File, 0, len(msg.Attachments))
 
 			for _, src := range msg.Attachments {
-				res, err := session.Client.Get(src.URL)
+				file, err := migrateDownload(work, session, src)
 				if err != nil {
+					slog.WarnContext(ctx, "migrate: attachment", "url", src.URL, "err", err)
+					attachmentErr = err
+					failedAttachments++
 					continue
 				}
-				attachments = append(attachments, &discordgo.File{
-					Name:        src.Filename,
-					ContentType: src.ContentType,
-					Reader:      res.Body,
-				})
-				defer res.Body.Close()
+				attachments = append(attachments, file)
 			}
 
 			param := &discordgo.WebhookParams{
@@ -494,23 +741,32 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 			}
 
 			if channel.IsThread() {
-				_, channelMigrateErr = session.WebhookThreadExecute(webhook.ID, webhook.Token, false, channel.ID, param)
+				_, channelMigrateErr = session.WebhookThreadExecute(webhook.ID, webhook.Token, false, channel.ID, param, discordgo.WithContext(work))
 			} else {
-				_, channelMigrateErr = session.WebhookExecute(webhook.ID, webhook.Token, false, param)
+				_, channelMigrateErr = session.WebhookExecute(webhook.ID, webhook.Token, false, param, discordgo.WithContext(work))
+			}
+			if channelMigrateErr == nil {
+				migratedMessages.Inc("channel")
 			}
 
-			if time.Since(start) > 700*time.Millisecond {
+			if time.Since(start) > cfg.Migrate.ProgressInterval {
 				start = time.Now()
-				desc = fmt.Sprintf("Migrated %v of %v messages...", idx+1, len(filtered))
-				migrateUpdateResponse(session, i.Interaction, desc, 0x11dddd)
+				desc = botctx.Tr(ctx, "migrate.migrated_count", idx+1, len(filtered))
+				migrateUpdateResponse(ctx, desc, cfg.Colors.Info)
 			}
 		}
 
-		if webhook != nil {
-			session.WebhookDelete(webhook.ID)
+		// The messages went through but without some of their files, which
+		// still has to be reported as a failed migration.
+		if channelMigrateErr == nil && failedAttachments > 0 {
+			channelMigrateErr = errors.New(botctx.Tr(ctx, "migrate.attachments_failed", failedAttachments, attachmentErr))
 		}
 	}
 
+	if work.Err() != nil {
+		return
+	}
+
 	var fileBuilder strings.Builder
 
 	if len(filename) > 0 {
@@ -536,29 +792,37 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 	content := ""
 
 	if channelMigrateErr == nil && channel != nil {
-		content += fmt.Sprintf("\n:green_circle: Migration from <#%+v> to <#%+v> complete.", i.ChannelID, channel.ID)
+		slog.InfoContext(ctx, "migrate: channel", "target_channel_id", channel.ID, "messages", len(filtered))
+		migrations.Inc("channel", "ok")
+		content += "\n" + botctx.Tr(ctx, "migrate.channel_done", i.ChannelID, channel.ID)
 	} else if channel != nil {
-		log.Printf("error: migrate: %+v\n", channelMigrateErr)
+		slog.ErrorContext(ctx, "migrate: channel", "target_channel_id", channel.ID, "err", channelMigrateErr)
+		migrations.Inc("channel", "failed")
 		if perr, ok := channelMigrateErr.(*discordgo.RESTError); ok {
 			err := make(map[string]interface{})
 			_ = json.Unmarshal(perr.ResponseBody, &err)
 			msg := err["message"]
-			content += fmt.Sprintf("\n:question: Migration from <#%+v> to <#%+v> failed: %+v", i.ChannelID, channel.ID, msg)
+			content += "\n" + botctx.Tr(ctx, "migrate.channel_failed", i.ChannelID, channel.ID, msg)
 		} else {
-			content += fmt.Sprintf("\n:question: Migration from <#%+v> to <#%+v> failed: %+v", i.ChannelID, channel.ID, channelMigrateErr.Error())
+			content += "\n" + botctx.Tr(ctx, "migrate.channel_failed", i.ChannelID, channel.ID, channelMigrateErr.Error())
 		}
 	}
 
 	if fileMigrateErr == nil && len(filename) > 0 {
-		content += fmt.Sprintf("\n:green_circle: Migration from <#%+v> to file %+v complete.", i.ChannelID, filename)
+		slog.InfoContext(ctx, "migrate: file", "file", filename, "messages", len(filtered))
+		migratedMessages.Add(float64(len(filtered)), "file")
+		migrations.Inc("file", "ok")
+		content += "\n" + botctx.Tr(ctx, "migrate.file_done", i.ChannelID, filename)
 	} else if len(filename) > 0 {
-		if perr, ok := channelMigrateErr.(*discordgo.RESTError); ok {
+		slog.ErrorContext(ctx, "migrate: file", "file", filename, "err", fileMigrateErr)
+		migrations.Inc("file", "failed")
+		if perr, ok := fileMigrateErr.(*discordgo.RESTError); ok {
 			err := make(map[string]interface{})
 			_ = json.Unmarshal(perr.ResponseBody, &err)
 			msg := err["message"]
-			content += fmt.Sprintf("\n:question: Migration from <#%+v> to file %+v failed: %+v", i.ChannelID, filename, msg)
+			content += "\n" + botctx.Tr(ctx, "migrate.file_failed", i.ChannelID, filename, msg)
 		} else {
-			content += fmt.Sprintf("\n:question: Migration from <#%+v> to file %+v failed: %+v", i.ChannelID, filename, fileMigrateErr.Error())
+			content += "\n" + botctx.Tr(ctx, "migrate.file_failed", i.ChannelID, filename, fileMigrateErr.Error())
 		}
 	}
 
@@ -575,20 +839,24 @@ func migrate(session *discordgo.Session, i *discordgo.InteractionCreate) {
 	res := &discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{
 			{
-				Title:       "Migration",
+				Title:       botctx.Tr(ctx, "migrate.title"),
 				Description: content,
 				Type:        discordgo.EmbedTypeRich,
-				Color:       0x11ff22,
+				Color:       cfg.Colors.Success,
 				Footer: &discordgo.MessageEmbedFooter{
-					Text: fmt.Sprintf("Total %+v messages", len(filtered)),
+					Text: botctx.Tr(ctx, "migrate.total", len(filtered)),
 				},
 			},
 		},
 		Files: files,
 	}
 
-	_, err := session.InteractionResponseEdit(i.Interaction, res)
+	if ctx.Err() != nil {
+		slog.WarnContext(ctx, "migrate: token expired, summary not sent", "messages", len(filtered))
+		return
+	}
+	_, err := botctx.ResponderFrom(ctx).Edit(res)
 	if err != nil {
-		log.Printf("error: migrate: %+v\n", err)
+		slog.ErrorContext(ctx, "migrate", "err", err)
 	}
 }
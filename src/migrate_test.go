@@ -0,0 +1,367 @@
+package main
+
+import (
+	"Raku/botctx"
+	"Raku/botctx/fake"
+	"Raku/config"
+	"Raku/discordtest"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMain(m *testing.M) {
+	botctx.SetupLogging(config.Log{Level: "error", Format: config.LogText})
+
+	localeFS, _ := fs.Sub(locales, "locales")
+	err := botctx.LoadLocales(localeFS)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	botctx.RegisterApplicationCommand(MigrateCommand)
+	os.Exit(m.Run())
+}
+
+const (
+	testGuildID  = "200000000000000001"
+	testSourceID = "200000000000000002"
+	testTargetID = "200000000000000003"
+)
+
+var (
+	testUser  = &discordgo.User{ID: "300000000000000001", Username: "kana"}
+	testOther = &discordgo.User{ID: "300000000000000002", Username: "bot", Bot: true}
+)
+
+// newMigrateServer is a guild where @everyone may migrate, with a source and
+// a target text channel.
+func newMigrateServer(targetOverwrites ...*discordgo.PermissionOverwrite) *discordtest.Server {
+	srv := discordtest.NewServer()
+	srv.AddGuild(&discordgo.Guild{
+		ID:      testGuildID,
+		Name:    "test",
+		OwnerID: "300000000000000099",
+		Roles: []*discordgo.Role{{
+			ID:          testGuildID,
+			Name:        "@everyone",
+			Permissions: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionReadMessageHistory | discordgo.PermissionManageWebhooks,
+		}},
+	})
+	srv.AddMember(testGuildID, &discordgo.Member{User: testUser})
+	srv.AddMember(testGuildID, &discordgo.Member{User: &discordgo.User{ID: discordtest.AppID, Bot: true}})
+	srv.AddChannel(&discordgo.Channel{ID: testSourceID, GuildID: testGuildID, Name: "source", Type: discordgo.ChannelTypeGuildText})
+	srv.AddChannel(&discordgo.Channel{ID: testTargetID, GuildID: testGuildID, Name: "target", Type: discordgo.ChannelTypeGuildText, PermissionOverwrites: targetOverwrites})
+	return srv
+}
+
+func migrateChannelInteraction(srv *discordtest.Server) *discordgo.InteractionCreate {
+	return srv.Interaction(testSourceID, testUser, discordgo.ApplicationCommandInteractionData{
+		Name: "migrate",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: "channel",
+			Type: discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "channel", Type: discordgo.ApplicationCommandOptionChannel, Value: testTargetID},
+				{Name: "mention", Type: discordgo.ApplicationCommandOptionBoolean, Value: false},
+			},
+		}},
+		Resolved: &discordgo.ApplicationCommandInteractionDataResolved{
+			Channels: map[string]*discordgo.Channel{testTargetID: srv.Channel(testTargetID)},
+		},
+	})
+}
+
+func TestMigrateChannel(t *testing.T) {
+	srv := newMigrateServer()
+	defer srv.Close()
+
+	start := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	msgs := srv.Generate(testSourceID, testUser, 3000, start, time.Minute)
+	srv.Generate(testSourceID, testOther, 500, start.Add(30*time.Second), 5*time.Minute)
+
+	// Empty messages are skipped unless they carry an attachment.
+	want := make([]string, 0, len(msgs))
+	for idx, msg := range msgs {
+		switch {
+		case idx%100 == 7:
+			msg.Content = ""
+		case idx == 1500:
+			msg.Content = ""
+			srv.AddAttachment(msg, "note.txt", "text/plain", []byte("attached"))
+			want = append(want, "")
+		default:
+			want = append(want, msg.Content)
+		}
+	}
+
+	botctx.HandleInteraction(botctx.Wrap(srv.Session()), migrateChannelInteraction(srv))
+
+	executions := srv.Executions()
+	if len(executions) != len(want) {
+		t.Fatalf("migrated %v messages, want %v", len(executions), len(want))
+	}
+	for idx, exec := range executions {
+		if exec.Params.Content != want[idx] {
+			t.Fatalf("message %v is %q, want %q", idx, exec.Params.Content, want[idx])
+		}
+		if exec.Params.Username != testUser.Username {
+			t.Fatalf("message %v posted as %q", idx, exec.Params.Username)
+		}
+		if exec.Params.Content == "" && (len(exec.Files) != 1 || string(exec.Files[0].Data) != "attached") {
+			t.Fatalf("message %v lost its attachment: %+v", idx, exec.Files)
+		}
+	}
+
+	if webhooks := srv.Webhooks(); len(webhooks) != 0 {
+		t.Errorf("%v webhooks left behind", len(webhooks))
+	}
+
+	edits := srv.Edits()
+	if len(edits) == 0 || len(edits[len(edits)-1].Params.Embeds) != 1 {
+		t.Fatalf("no summary embed in %+v", edits)
+	}
+	summary := edits[len(edits)-1].Params.Embeds[0]
+	done := botctx.Translate(discordgo.EnglishUS, "migrate.channel_done", testSourceID, testTargetID)
+	if !strings.Contains(summary.Description, done) {
+		t.Errorf("summary %q does not contain %q", summary.Description, done)
+	}
+	total := botctx.Translate(discordgo.EnglishUS, "migrate.total", len(want))
+	if summary.Footer == nil || summary.Footer.Text != total {
+		t.Errorf("summary footer is %+v, want %q", summary.Footer, total)
+	}
+}
+
+func TestMigrateChannelMissingPermissions(t *testing.T) {
+	srv := newMigrateServer(&discordgo.PermissionOverwrite{
+		ID:   testGuildID,
+		Type: discordgo.PermissionOverwriteTypeRole,
+		Deny: discordgo.PermissionSendMessages,
+	})
+	defer srv.Close()
+
+	srv.Generate(testSourceID, testUser, 10, time.Now().Add(-time.Hour), time.Minute)
+
+	botctx.HandleInteraction(botctx.Wrap(srv.Session()), migrateChannelInteraction(srv))
+
+	if n := len(srv.Executions()); n != 0 {
+		t.Fatalf("migrated %v messages without permission", n)
+	}
+	callbacks := srv.Callbacks()
+	if len(callbacks) != 1 || callbacks[0].Data == nil || len(callbacks[0].Data.Embeds) != 1 {
+		t.Fatalf("unexpected responses %+v", callbacks)
+	}
+	title := botctx.Translate(discordgo.EnglishUS, "botctx.permissions.title")
+	if embed := callbacks[0].Data.Embeds[0]; embed.Title != title {
+		t.Errorf("responded %q, want %q", embed.Title, title)
+	}
+}
+
+func TestMigrateChannelPermissionsUnavailable(t *testing.T) {
+	srv := newMigrateServer()
+	defer srv.Close()
+
+	// The target is in a guild the server does not know, so its
+	// permissions cannot be fetched.
+	srv.AddChannel(&discordgo.Channel{ID: testTargetID, GuildID: "200000000000000009", Name: "target", Type: discordgo.ChannelTypeGuildText})
+
+	botctx.HandleInteraction(botctx.Wrap(srv.Session()), migrateChannelInteraction(srv))
+
+	callbacks := srv.Callbacks()
+	if len(callbacks) != 1 || callbacks[0].Data == nil || len(callbacks[0].Data.Embeds) != 1 {
+		t.Fatalf("unexpected responses %+v", callbacks)
+	}
+	embed := callbacks[0].Data.Embeds[0]
+	title := botctx.Translate(discordgo.EnglishUS, "botctx.error.title")
+	desc := botctx.Translate(discordgo.EnglishUS, "botctx.error.unexpected")
+	if embed.Title != title || embed.Description != desc {
+		t.Errorf("responded %q: %q, want %q: %q", embed.Title, embed.Description, title, desc)
+	}
+}
+
+// fakeMessages are n messages from testUser in testSourceID every minute,
+// newest first like discord returns them, with every tenth one from a bot
+// and every seventh one empty.
+func fakeMessages(n int, start time.Time) []*discordgo.Message {
+	msgs := make([]*discordgo.Message, n)
+	for idx := 0; idx < n; idx++ {
+		ts := start.Add(time.Duration(idx) * time.Minute)
+		msg := &discordgo.Message{
+			ID:        discordtest.Snowflake(ts, idx),
+			ChannelID: testSourceID,
+			Content:   fmt.Sprintf("message %v", idx),
+			Author:    testUser,
+			Timestamp: ts,
+		}
+		switch {
+		case idx%10 == 9:
+			msg.Author = testOther
+		case idx%7 == 6:
+			msg.Content = ""
+		}
+		msgs[n-1-idx] = msg
+	}
+	return msgs
+}
+
+func TestDoMigrate(t *testing.T) {
+	start := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	thread := &discordgo.Channel{ID: "200000000000000004", GuildID: testGuildID, ParentID: testTargetID, Type: discordgo.ChannelTypeGuildPublicThread}
+	voice := &discordgo.Channel{ID: "200000000000000005", GuildID: testGuildID, Name: "voice", Type: discordgo.ChannelTypeGuildVoice}
+
+	tests := []struct {
+		name   string
+		req    migrateRequest
+		errors map[string]error
+		// migrated are the indexes of the messages expected in the target.
+		migrated []int
+		thread   string
+		file     string
+		summary  string
+	}{
+		{
+			name:     "channel",
+			req:      migrateRequest{channel: &discordgo.Channel{ID: testTargetID, Type: discordgo.ChannelTypeGuildText}},
+			migrated: []int{0, 1, 2, 3, 4, 5, 7, 8, 10, 11, 12, 14, 15, 16, 17},
+			summary:  botctx.Translate(discordgo.EnglishUS, "migrate.channel_done", testSourceID, testTargetID),
+		},
+		{
+			name:     "thread",
+			req:      migrateRequest{channel: thread},
+			migrated: []int{0, 1, 2, 3, 4, 5, 7, 8, 10, 11, 12, 14, 15, 16, 17},
+			thread:   thread.ID,
+			summary:  botctx.Translate(discordgo.EnglishUS, "migrate.channel_done", testSourceID, thread.ID),
+		},
+		{
+			name:    "webhook failed",
+			req:     migrateRequest{channel: &discordgo.Channel{ID: testTargetID, Type: discordgo.ChannelTypeGuildText}},
+			errors:  map[string]error{"WebhookCreate": errors.New("no webhooks left")},
+			summary: botctx.Translate(discordgo.EnglishUS, "migrate.channel_failed", testSourceID, testTargetID, "no webhooks left"),
+		},
+		{
+			name:    "file",
+			req:     migrateRequest{filename: "archive"},
+			file:    "archive.json",
+			summary: botctx.Translate(discordgo.EnglishUS, "migrate.file_done", testSourceID, "archive.json"),
+		},
+		{
+			name:     "date range",
+			req:      migrateRequest{channel: &discordgo.Channel{ID: testTargetID, Type: discordgo.ChannelTypeGuildText}, after: start.Add(3 * time.Minute), before: start.Add(11 * time.Minute)},
+			migrated: []int{3, 4, 5, 7, 8, 10},
+			summary:  botctx.Translate(discordgo.EnglishUS, "migrate.channel_done", testSourceID, testTargetID),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := fake.New()
+			session.Channels[testSourceID] = &discordgo.Channel{ID: testSourceID, GuildID: testGuildID, Name: "source", Type: discordgo.ChannelTypeGuildText}
+			session.Messages[testSourceID] = fakeMessages(18, start)
+			for method, err := range test.errors {
+				session.Errors[method] = err
+			}
+
+			i := commandInteraction()
+			ctx := botctx.WithInteraction(context.Background(), session, i)
+			doMigrate(ctx, session, i, test.req)
+
+			if len(session.Executions) != len(test.migrated) {
+				t.Fatalf("migrated %v messages, want %v", len(session.Executions), len(test.migrated))
+			}
+			for idx, exec := range session.Executions {
+				want := fmt.Sprintf("message %v", test.migrated[idx])
+				if exec.Params.Content != want || exec.ThreadID != test.thread {
+					t.Errorf("message %v is %q in thread %q, want %q in %q", idx, exec.Params.Content, exec.ThreadID, want, test.thread)
+				}
+			}
+			if len(session.Webhooks) != 0 {
+				t.Errorf("%v webhooks left behind", len(session.Webhooks))
+			}
+
+			if len(session.Edits) == 0 {
+				t.Fatal("no summary")
+			}
+			summary := session.Edits[len(session.Edits)-1]
+			if summary.Embeds == nil || len(*summary.Embeds) != 1 || !strings.Contains((*summary.Embeds)[0].Description, test.summary) {
+				t.Fatalf("summary %+v does not contain %q", summary.Embeds, test.summary)
+			}
+
+			if test.file != "" {
+				if len(summary.Files) != 1 || summary.Files[0].Name != test.file {
+					t.Fatalf("attached %+v, want %v", summary.Files, test.file)
+				}
+				var archived []*discordgo.Message
+				err := json.NewDecoder(summary.Files[0].Reader).Decode(&archived)
+				if err != nil || len(archived) != 15 {
+					t.Errorf("archived %v messages, %v", len(archived), err)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid channel", func(t *testing.T) {
+		session := fake.New()
+		i := commandInteraction()
+		ctx := botctx.WithInteraction(context.Background(), session, i)
+		doMigrate(ctx, session, i, migrateRequest{channel: voice})
+
+		embed := responseEmbed(t, session)
+		if embed.Title != botctx.Translate(discordgo.EnglishUS, "migrate.failed") || session.Responses[0].Data.Flags != discordgo.MessageFlagsEphemeral {
+			t.Errorf("responded %+v", session.Responses[0].Data)
+		}
+		if len(session.Edits) != 0 || len(session.Executions) != 0 {
+			t.Errorf("migrated after an invalid channel")
+		}
+	})
+
+	t.Run("attachment failed", func(t *testing.T) {
+		files := httptest.NewServer(http.NotFoundHandler())
+		defer files.Close()
+
+		session := fake.New()
+		session.Channels[testSourceID] = &discordgo.Channel{ID: testSourceID, GuildID: testGuildID, Name: "source", Type: discordgo.ChannelTypeGuildText}
+		session.Messages[testSourceID] = fakeMessages(2, start)
+		session.Messages[testSourceID][0].Attachments = []*discordgo.MessageAttachment{{URL: files.URL + "/image.png", Filename: "image.png"}}
+
+		i := commandInteraction()
+		ctx := botctx.WithInteraction(context.Background(), session, i)
+		doMigrate(ctx, session, i, migrateRequest{channel: &discordgo.Channel{ID: testTargetID, Type: discordgo.ChannelTypeGuildText}})
+
+		if len(session.Executions) != 2 {
+			t.Fatalf("migrated %v messages, want 2", len(session.Executions))
+		}
+		reason := botctx.Translate(discordgo.EnglishUS, "migrate.attachments_failed", 1, "404 Not Found")
+		want := botctx.Translate(discordgo.EnglishUS, "migrate.channel_failed", testSourceID, testTargetID, reason)
+		summary := session.Edits[len(session.Edits)-1]
+		if summary.Embeds == nil || len(*summary.Embeds) != 1 || !strings.Contains((*summary.Embeds)[0].Description, want) {
+			t.Fatalf("summary %+v does not contain %q", summary.Embeds, want)
+		}
+	})
+
+	t.Run("mention in guild locale", func(t *testing.T) {
+		session := fake.New()
+		session.Channels[testSourceID] = &discordgo.Channel{ID: testSourceID, GuildID: testGuildID, Name: "source", Type: discordgo.ChannelTypeGuildText}
+		session.Messages[testSourceID] = fakeMessages(1, start)
+
+		i := commandInteraction()
+		guildLocale := discordgo.Japanese
+		i.Locale, i.GuildLocale = discordgo.EnglishUS, &guildLocale
+		ctx := botctx.WithInteraction(context.Background(), session, i)
+		doMigrate(ctx, session, i, migrateRequest{channel: &discordgo.Channel{ID: testTargetID, Type: discordgo.ChannelTypeGuildText}, mention: true})
+
+		want := "message 0" + botctx.Translate(discordgo.Japanese, "migrate.original_author", testUser.ID)
+		if len(session.Executions) != 1 || session.Executions[0].Params.Content != want {
+			t.Errorf("migrated %+v, want %q", session.Executions, want)
+		}
+	})
+}